@@ -0,0 +1,328 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ReadAnswersFile reads the survey answers from a yaml or json file
+func ReadAnswersFile(path string) (map[string]interface{}, error) {
+	answers := map[string]interface{}{}
+
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read answers file")
+	}
+
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(dat, &answers)
+	} else {
+		err = yaml.Unmarshal(dat, &answers)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "answers file '%s' could not be unmarshaled", path)
+	}
+
+	return answers, nil
+}
+
+// Condition reports whether the question is asked with the answers given so far
+type Condition func(question Question, answers map[string]interface{}) (bool, error)
+
+// QuestionResolver returns the question with the defaults and options computed
+// from the answers given so far. Only the default is computed for skipped questions.
+type QuestionResolver func(question Question, answers map[string]interface{}, asked bool) (Question, error)
+
+// ValidateAnswers checks the answers against the questions of the survey and
+// returns the result with the same types a interactive survey would produce.
+// Missing answers and the answers of questions which are disabled by the condition
+// are replaced by their defaults. The answers have to satisfy the validation rules.
+func ValidateAnswers(s *Survey, answers map[string]interface{}, enabled Condition, resolve QuestionResolver, valid AnswerValidator) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	known := map[string]struct{}{}
+	msgs := []string{}
+
+	for _, question := range s.Questions {
+		known[question.Name] = struct{}{}
+
+		asked := true
+		if enabled != nil {
+			var err error
+			asked, err = enabled(question, result)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if resolve != nil {
+			var err error
+			question, err = resolve(question, result, asked)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		value, ok := answers[question.Name]
+		if !asked {
+			v, err := normalizeAnswer(question, question.Default)
+			if err != nil {
+				msgs = append(msgs, fmt.Sprintf("invalid default for question '%s': %s", question.Name, err))
+				continue
+			}
+			result[question.Name] = v
+			continue
+		}
+		if !ok || value == nil {
+			if question.Default == nil && question.Required {
+				msgs = append(msgs, fmt.Sprintf("missing answer for question '%s' (%s)", question.Name, question.Message))
+				continue
+			}
+			value = question.Default
+		}
+
+		v, err := normalizeAnswer(question, value)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("invalid answer for question '%s': %s", question.Name, err))
+			continue
+		}
+
+		if question.Required && isEmptyAnswer(v) {
+			msgs = append(msgs, fmt.Sprintf("missing answer for question '%s' (%s)", question.Name, question.Message))
+			continue
+		}
+
+		// invalid answers are still accessible in the expressions of the next questions
+		err = validateRules(question, v, result, valid)
+		result[question.Name] = v
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("invalid answer for question '%s': %s", question.Name, err))
+		}
+	}
+
+	for name := range answers {
+		if _, ok := known[name]; !ok {
+			msgs = append(msgs, fmt.Sprintf("unknown question '%s'", name))
+		}
+	}
+
+	if len(msgs) > 0 {
+		sort.Strings(msgs)
+		return nil, errors.Errorf("invalid answers:\n  %s", strings.Join(msgs, "\n  "))
+	}
+
+	return result, nil
+}
+
+// normalizeAnswer converts the raw answer in the type of the survey prompt
+func normalizeAnswer(question Question, value interface{}) (interface{}, error) {
+	switch question.Type {
+	case "input", "password":
+		if value == nil {
+			return "", nil
+		}
+		return toAnswerString(value)
+	case "select":
+		if value == nil {
+			return "", nil
+		}
+		str, err := toAnswerString(value)
+		if err != nil {
+			return nil, err
+		}
+		if str != "" && !hasOption(question, str) {
+			return nil, fmt.Errorf("'%s' is not one of [%s]", str, strings.Join(question.Options, ", "))
+		}
+		return str, nil
+	case "confirm":
+		if value == nil {
+			return false, nil
+		}
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected a boolean but got '%v'", value)
+		}
+		return b, nil
+	case "multiselect":
+		list := []string{}
+		if value == nil {
+			return list, nil
+		}
+		// the survey returns strings, answer files a generic list
+		if strs, ok := value.([]string); ok {
+			value = toInterfaces(strs)
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a list but got '%v'", value)
+		}
+		for _, item := range items {
+			str, err := toAnswerString(item)
+			if err != nil {
+				return nil, err
+			}
+			if !hasOption(question, str) {
+				return nil, fmt.Errorf("'%s' is not one of [%s]", str, strings.Join(question.Options, ", "))
+			}
+			list = append(list, str)
+		}
+		return list, nil
+	case "number":
+		if value == nil {
+			return 0, nil
+		}
+		return toAnswerNumber(value)
+	case "path":
+		if value == nil {
+			return "", nil
+		}
+		str, err := toAnswerString(value)
+		if err != nil {
+			return nil, err
+		}
+		return expandHome(str), nil
+	case "editor":
+		if value == nil {
+			return "", nil
+		}
+		return toAnswerString(value)
+	case "list":
+		list := []string{}
+		if value == nil {
+			return list, nil
+		}
+		if strs, ok := value.([]string); ok {
+			value = toInterfaces(strs)
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a list but got '%v'", value)
+		}
+		for _, item := range items {
+			str, err := toAnswerString(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, str)
+		}
+		return list, nil
+	case "map":
+		pairs := map[string]string{}
+		if value == nil {
+			return pairs, nil
+		}
+		switch m := value.(type) {
+		case map[string]string:
+			for k, v := range m {
+				pairs[k] = v
+			}
+		case map[string]interface{}:
+			for k, v := range m {
+				str, err := toAnswerString(v)
+				if err != nil {
+					return nil, err
+				}
+				pairs[k] = str
+			}
+		// yaml decodes maps with interface keys
+		case map[interface{}]interface{}:
+			for k, v := range m {
+				str, err := toAnswerString(v)
+				if err != nil {
+					return nil, err
+				}
+				pairs[fmt.Sprint(k)] = str
+			}
+		default:
+			return nil, fmt.Errorf("expected key/value pairs but got '%v'", value)
+		}
+		return pairs, nil
+	default:
+		return nil, fmt.Errorf("invalid prompt type %s", question.Type)
+	}
+}
+
+// hasOption reports whether the value is one of the options of the question. The
+// options of a source which wasn't loaded e.g of a skipped question are unknown.
+func hasOption(question Question, value string) bool {
+	return question.OptionsFrom != nil || containsString(question.Options, value)
+}
+
+// toAnswerNumber converts the value to an int or a float64 when it has a fraction
+func toAnswerNumber(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return floatAnswer(v), nil
+	case string:
+		v = strings.TrimSpace(v)
+		if i, err := strconv.Atoi(v); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return floatAnswer(f), nil
+		}
+	}
+	return nil, fmt.Errorf("expected a number but got '%v'", value)
+}
+
+// floatAnswer returns whole numbers as int because json files decode all numbers
+// as float64 while the prompt and yaml files return an int
+func floatAnswer(f float64) interface{} {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return int(f)
+	}
+	return f
+}
+
+// toAnswerString converts scalar values to string, numbers are common in yaml files
+func toAnswerString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int, int64, float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("expected a string but got '%v'", value)
+	}
+}
+
+func isEmptyAnswer(value interface{}) bool {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []string:
+		return len(v) == 0
+	case map[string]string:
+		return len(v) == 0
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func toInterfaces(list []string) []interface{} {
+	items := make([]interface{}, len(list))
+	for i, v := range list {
+		items[i] = v
+	}
+	return items
+}
@@ -0,0 +1,68 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestToAnswerNumber(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  interface{}
+	}{
+		{8080, 8080},
+		{int64(8080), 8080},
+		{float64(8080), 8080},
+		{-1.0, -1},
+		{1.5, 1.5},
+		{1e20, 1e20},
+		{"8080", 8080},
+		{" 8080.0 ", 8080},
+		{"0.25", 0.25},
+	}
+
+	for _, tt := range tests {
+		got, err := toAnswerNumber(tt.value)
+		if err != nil {
+			t.Errorf("%v: %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%v: expected %v (%T), got %v (%T)", tt.value, tt.want, tt.want, got, got)
+		}
+	}
+
+	for _, value := range []interface{}{"port", true, nil} {
+		if _, err := toAnswerNumber(value); err == nil {
+			t.Errorf("%v: expected an error", value)
+		}
+	}
+}
+
+func TestAnswersFileNumbers(t *testing.T) {
+	s := &Survey{Questions: []Question{{Name: "port", Type: "number", Message: "Port"}}}
+
+	for name, content := range map[string]string{
+		"answers.json": `{"port": 8080}`,
+		"answers.yml":  "port: 8080\n",
+	} {
+		dir := testTempDir(t)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, name)
+		writeTestFile(t, path, content, 0644)
+
+		answers, err := ReadAnswersFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := ValidateAnswers(s, answers, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(result, map[string]interface{}{"port": 8080}) {
+			t.Errorf("%s: expected the int 8080, got %#v", name, result)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	logy "github.com/apex/log"
+	"github.com/netzkern/butler/config"
+	"github.com/netzkern/butler/utils"
+	"github.com/pkg/errors"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+)
+
+// archiveClient downloads the archives. A stalled server aborts the download instead
+// of blocking the run forever.
+var archiveClient = &http.Client{
+	Timeout: 10 * time.Minute,
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+// archiveFormat returns the format of the archive the url points to or an empty
+// string when it isn't an archive
+func archiveFormat(url string) string {
+	name := strings.ToLower(url)
+	if isHTTPURL(name) {
+		// ignore the query of download links
+		if i := strings.IndexAny(name, "?#"); i != -1 {
+			name = name[:i]
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	}
+
+	return ""
+}
+
+func isHTTPURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
+
+// unpackArchive downloads the archive, verifies the checksum and extracts it to the dst
+func (t *Templating) unpackArchive(tpl *config.Template, dest string) error {
+	logy.Debugf("unpack archive from %s to %s", tpl.URL, dest)
+
+	if tpl.Ref != "" || t.revision != "" {
+		return errors.Errorf("archive template '%s' has no versions, remove the ref", tpl.Name)
+	}
+
+	tempDir, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		return errors.Wrap(err, "create temp folder failed")
+	}
+	defer os.RemoveAll(tempDir)
+
+	archive := tpl.URL
+	if isHTTPURL(tpl.URL) {
+		archive = filepath.Join(tempDir, "archive")
+		err = t.downloadArchive(tpl, archive)
+		if err != nil {
+			return err
+		}
+	}
+
+	checksum, err := fileChecksum(archive)
+	if err != nil {
+		return errors.Wrap(err, "archive checksum")
+	}
+	if tpl.Checksum != "" {
+		expected := strings.ToLower(strings.TrimPrefix(tpl.Checksum, "sha256:"))
+		if expected != checksum {
+			return errors.Errorf("checksum mismatch of '%s', expected sha256 %s but got %s", tpl.URL, expected, checksum)
+		}
+	}
+	t.checksum = checksum
+
+	root := filepath.Join(tempDir, "root")
+	err = utils.ExtractArchive(archive, root, archiveFormat(tpl.URL))
+	if err != nil {
+		return errors.Wrapf(err, "extract archive '%s'", tpl.URL)
+	}
+
+	// archives from artifact stores often wrap the files in a single directory
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return errors.Wrap(err, "read archive")
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		root = filepath.Join(root, entries[0].Name())
+	}
+
+	err = utils.MoveDir(filepath.Join(root, tpl.Path), dest, t.copyOptions()...)
+	if err != nil {
+		return errors.Wrapf(err, "template path '%s' could not be copied", tpl.Path)
+	}
+
+	return nil
+}
+
+// downloadArchive downloads the archive to the file. The http credentials of the
+// template are used for private artifact stores.
+func (t *Templating) downloadArchive(tpl *config.Template, filename string) error {
+	req, err := http.NewRequest(http.MethodGet, tpl.URL, nil)
+	if err != nil {
+		return errors.Wrapf(err, "invalid archive url '%s'", tpl.URL)
+	}
+
+	auth, err := gitAuthMethod(tpl.URL, tpl.Auth, t.gitAuth)
+	if err != nil {
+		return err
+	}
+	if basic, ok := auth.(*githttp.BasicAuth); ok {
+		req.SetBasicAuth(basic.Username, basic.Password)
+	}
+
+	resp, err := archiveClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "download archive '%s'", tpl.URL)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return errors.Errorf("authentication required for '%s'. Check the credentials of the template", tpl.URL)
+	case http.StatusForbidden:
+		return errors.Errorf("authorization failed for '%s', your credentials have no access to the archive", tpl.URL)
+	case http.StatusNotFound:
+		return errors.Errorf("archive '%s' could not be found", tpl.URL)
+	default:
+		return errors.Errorf("download archive '%s' failed with status %s", tpl.URL, resp.Status)
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, io.LimitReader(resp.Body, utils.MaxArchiveSize+1))
+	if err != nil {
+		return errors.Wrapf(err, "download archive '%s'", tpl.URL)
+	}
+	if n > utils.MaxArchiveSize {
+		return errors.Errorf("archive '%s' exceeds the maximum size of %d bytes", tpl.URL, int64(utils.MaxArchiveSize))
+	}
+
+	return nil
+}
+
+// fileChecksum returns the hex encoded sha256 checksum of the file
+func fileChecksum(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
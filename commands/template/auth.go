@@ -0,0 +1,218 @@
+package template
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	logy "github.com/apex/log"
+	"github.com/netzkern/butler/config"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+	gitssh "gopkg.in/src-d/go-git.v4/plumbing/transport/ssh"
+)
+
+const (
+	defaultUsernameEnv = "BUTLER_GIT_USERNAME"
+	defaultPasswordEnv = "BUTLER_GIT_PASSWORD"
+	// username for token based authentication, the value is ignored by most git servers
+	defaultTokenUser = "git"
+)
+
+// gitAuthMethod returns the auth method to access the repository. The template credentials
+// take precedence over the global credentials. Nil is returned when the url doesn't require
+// any credentials.
+func gitAuthMethod(url string, tplAuth, globalAuth *config.GitAuth) (transport.AuthMethod, error) {
+	auth := tplAuth
+	if auth == nil {
+		auth = globalAuth
+	}
+	if auth == nil {
+		auth = &config.GitAuth{}
+	}
+
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid repository url '%s'", url)
+	}
+
+	switch endpoint.Protocol {
+	case "ssh":
+		return sshAuthMethod(endpoint, auth)
+	case "http", "https":
+		return httpAuthMethod(endpoint, auth)
+	}
+
+	return nil, nil
+}
+
+// sshAuthMethod authenticates with a private key file or the ssh agent. The host key
+// is verified against the known_hosts files.
+func sshAuthMethod(endpoint *transport.Endpoint, auth *config.GitAuth) (transport.AuthMethod, error) {
+	username := endpoint.User
+	if username == "" {
+		username = defaultTokenUser
+	}
+
+	var knownHosts []string
+	if auth.KnownHosts != "" {
+		knownHosts = append(knownHosts, expandHome(auth.KnownHosts))
+	}
+	hostKeyCallback, err := gitssh.NewKnownHostsCallback(knownHosts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "known hosts")
+	}
+
+	if auth.SSHKey != "" && !auth.SSHAgent {
+		logy.Debugf("ssh authentication with key '%s'", auth.SSHKey)
+		keys, err := gitssh.NewPublicKeysFromFile(username, expandHome(auth.SSHKey), envValue(auth.SSHKeyPassEnv))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not load ssh key '%s'", auth.SSHKey)
+		}
+		keys.HostKeyCallback = hostKeyCallback
+		return keys, nil
+	}
+
+	logy.Debug("ssh authentication with ssh agent")
+	agent, err := gitssh.NewSSHAgentAuth(username)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to ssh agent")
+	}
+	agent.HostKeyCallback = hostKeyCallback
+
+	return agent, nil
+}
+
+// httpAuthMethod authenticates with username and password or token from environment
+// variables or with the git credential helper
+func httpAuthMethod(endpoint *transport.Endpoint, auth *config.GitAuth) (transport.AuthMethod, error) {
+	usernameEnv, passwordEnv := auth.UsernameEnv, auth.PasswordEnv
+	if usernameEnv == "" {
+		usernameEnv = defaultUsernameEnv
+	}
+	if passwordEnv == "" {
+		passwordEnv = defaultPasswordEnv
+	}
+
+	username := auth.Username
+	if v := envValue(usernameEnv); v != "" {
+		username = v
+	}
+	password := envValue(passwordEnv)
+
+	if password != "" {
+		if username == "" {
+			username = defaultTokenUser
+		}
+		logy.Debugf("http authentication with credentials from '%s'", passwordEnv)
+		return &githttp.BasicAuth{Username: username, Password: password}, nil
+	}
+
+	if auth.CredentialHelper {
+		username, password, err := gitCredentialFill(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		logy.Debug("http authentication with git credential helper")
+		return &githttp.BasicAuth{Username: username, Password: password}, nil
+	}
+
+	return nil, nil
+}
+
+// gitCredentialFill asks the git credential helper for the credentials of the endpoint
+func gitCredentialFill(endpoint *transport.Endpoint) (string, string, error) {
+	host := endpoint.Host
+	if endpoint.Port != 0 {
+		host = fmt.Sprintf("%s:%d", endpoint.Host, endpoint.Port)
+	}
+
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf(
+		"protocol=%s\nhost=%s\npath=%s\nusername=%s\n\n",
+		endpoint.Protocol,
+		host,
+		strings.TrimPrefix(endpoint.Path, "/"),
+		endpoint.User,
+	))
+	// never prompt in the terminal
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", errors.Wrap(err, "git credential helper")
+	}
+
+	var username, password string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "username="):
+			username = strings.TrimPrefix(line, "username=")
+		case strings.HasPrefix(line, "password="):
+			password = strings.TrimPrefix(line, "password=")
+		}
+	}
+
+	if password == "" {
+		return "", "", errors.Errorf("git credential helper returned no password for '%s'", host)
+	}
+
+	return username, password, nil
+}
+
+// gitError replaces the transport errors with messages which distinguish
+// authentication failures from missing repositories
+func gitError(url string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch err {
+	case transport.ErrAuthenticationRequired:
+		return errors.Errorf("authentication required for '%s', the repository is private or doesn't exist. Check the credentials of the template", url)
+	case transport.ErrAuthorizationFailed:
+		return errors.Errorf("authorization failed for '%s', your credentials have no access to the repository", url)
+	case transport.ErrRepositoryNotFound:
+		return errors.Errorf("repository '%s' could not be found", url)
+	case transport.ErrEmptyRemoteRepository:
+		return errors.Errorf("repository '%s' is empty", url)
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "unable to authenticate"):
+		return errors.Wrapf(err, "ssh authentication failed for '%s'", url)
+	case strings.Contains(msg, "knownhosts"):
+		return errors.Wrapf(err, "host key verification failed for '%s', add the host to your known_hosts file", url)
+	}
+
+	return err
+}
+
+// expandHome replaces a leading "~" with the home directory of the current user
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(usr.HomeDir, strings.TrimPrefix(path, "~"))
+}
+
+// envValue returns the value of the environment variable when the name isn't empty
+func envValue(name string) string {
+	if name == "" {
+		return ""
+	}
+	return os.Getenv(name)
+}
@@ -0,0 +1,299 @@
+package template
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netzkern/butler/config"
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	githttp "gopkg.in/src-d/go-git.v4/plumbing/transport/http"
+)
+
+func TestGitError(t *testing.T) {
+	url := "https://example.com/org/repo.git"
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"authentication", transport.ErrAuthenticationRequired, "authentication required for '" + url + "'"},
+		{"authorization", transport.ErrAuthorizationFailed, "authorization failed for '" + url + "'"},
+		{"not found", transport.ErrRepositoryNotFound, "repository '" + url + "' could not be found"},
+		{"empty", transport.ErrEmptyRemoteRepository, "repository '" + url + "' is empty"},
+		{"ssh", errors.New("ssh: handshake failed: ssh: unable to authenticate"), "ssh authentication failed for '" + url + "'"},
+		{"known hosts", errors.New("ssh: handshake failed: knownhosts: key is unknown"), "host key verification failed for '" + url + "'"},
+		{"other", errors.New("connection refused"), "connection refused"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := gitError(url, tt.err)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestHTTPAuthMethod(t *testing.T) {
+	defer os.Unsetenv(defaultUsernameEnv)
+	defer os.Unsetenv(defaultPasswordEnv)
+	defer os.Unsetenv("TEST_GIT_TOKEN")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		auth *config.GitAuth
+		want *githttp.BasicAuth
+	}{
+		{"no credentials", nil, &config.GitAuth{}, nil},
+		{
+			"default env",
+			map[string]string{defaultUsernameEnv: "user", defaultPasswordEnv: "secret"},
+			&config.GitAuth{},
+			&githttp.BasicAuth{Username: "user", Password: "secret"},
+		},
+		{
+			"token without username",
+			map[string]string{defaultPasswordEnv: "token"},
+			&config.GitAuth{},
+			&githttp.BasicAuth{Username: defaultTokenUser, Password: "token"},
+		},
+		{
+			"custom env and configured username",
+			map[string]string{"TEST_GIT_TOKEN": "token"},
+			&config.GitAuth{Username: "bot", PasswordEnv: "TEST_GIT_TOKEN"},
+			&githttp.BasicAuth{Username: "bot", Password: "token"},
+		},
+	}
+
+	endpoint, err := transport.NewEndpoint("https://example.com/org/repo.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{defaultUsernameEnv, defaultPasswordEnv, "TEST_GIT_TOKEN"} {
+				os.Unsetenv(name)
+			}
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+			}
+
+			method, err := httpAuthMethod(endpoint, tt.auth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertBasicAuth(t, method, tt.want)
+		})
+	}
+}
+
+func TestHTTPAuthMethodCredentialHelper(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	home, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	gitconfig := "[credential]\n\thelper = \"!f() { echo username=helper; echo password=from-helper; }; f\"\n"
+	err = ioutil.WriteFile(filepath.Join(home, ".gitconfig"), []byte(gitconfig), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k, v := range map[string]string{"HOME": home, "XDG_CONFIG_HOME": home, "GIT_CONFIG_NOSYSTEM": "1"} {
+		defer os.Setenv(k, os.Getenv(k))
+		os.Setenv(k, v)
+	}
+	os.Unsetenv(defaultPasswordEnv)
+
+	endpoint, err := transport.NewEndpoint("https://example.com/org/repo.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	method, err := httpAuthMethod(endpoint, &config.GitAuth{CredentialHelper: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBasicAuth(t, method, &githttp.BasicAuth{Username: "helper", Password: "from-helper"})
+
+	// the env variables take precedence over the helper
+	os.Setenv(defaultPasswordEnv, "from-env")
+	defer os.Unsetenv(defaultPasswordEnv)
+	method, err = httpAuthMethod(endpoint, &config.GitAuth{CredentialHelper: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBasicAuth(t, method, &githttp.BasicAuth{Username: defaultTokenUser, Password: "from-env"})
+}
+
+func TestGitAuthMethodLocal(t *testing.T) {
+	method, err := gitAuthMethod("file:///tmp/repo", nil, &config.GitAuth{SSHAgent: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != nil {
+		t.Fatalf("expected no auth method for a local repository, got %v", method)
+	}
+}
+
+func TestUnpackGitRepositoryFileURL(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	repoDir, repo := initTestRepo(t)
+	defer os.RemoveAll(repoDir)
+
+	first := commitTestFile(t, repo, repoDir, "a.txt", "v1")
+	_, err := repo.CreateTag("v1.0.0", first, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := commitTestFile(t, repo, repoDir, "a.txt", "v2")
+
+	tests := []struct {
+		name   string
+		ref    string
+		want   string
+		commit plumbing.Hash
+	}{
+		{"default branch", "", "v2", second},
+		{"tag", "v1.0.0", "v1", first},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest, err := ioutil.TempDir("", "butler")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dest)
+
+			tpl := &config.Template{Name: "test", URL: "file://" + repoDir, Ref: tt.ref}
+			command := New()
+			err = command.unpackGitRepository(tpl, dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dat, err := ioutil.ReadFile(filepath.Join(dest, "a.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(dat) != tt.want {
+				t.Errorf("expected content %q, got %q", tt.want, dat)
+			}
+			if command.commit != tt.commit.String() {
+				t.Errorf("expected commit %s, got %s", tt.commit, command.commit)
+			}
+			if _, err := os.Stat(filepath.Join(dest, ".git")); !os.IsNotExist(err) {
+				t.Errorf("expected the git files to be removed")
+			}
+		})
+	}
+}
+
+func TestUnpackGitRepositoryNotFound(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	dest, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dest)
+
+	tpl := &config.Template{Name: "test", URL: "file://" + filepath.Join(dest, "missing")}
+	err = New().unpackGitRepository(tpl, filepath.Join(dest, "out"))
+	if err == nil {
+		t.Fatal("expected an error for a missing repository")
+	}
+}
+
+// assertBasicAuth compares the auth method with the expected credentials
+func assertBasicAuth(t *testing.T, method transport.AuthMethod, want *githttp.BasicAuth) {
+	t.Helper()
+	if want == nil {
+		if method != nil {
+			t.Fatalf("expected no auth method, got %v", method)
+		}
+		return
+	}
+	got, ok := method.(*githttp.BasicAuth)
+	if !ok {
+		t.Fatalf("expected basic auth, got %T", method)
+	}
+	if got.Username != want.Username || got.Password != want.Password {
+		t.Fatalf("expected %s:%s, got %s:%s", want.Username, want.Password, got.Username, got.Password)
+	}
+}
+
+// initTestRepo creates a git repository in a temp directory
+func initTestRepo(t *testing.T) (string, *git.Repository) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "butler-repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir, repo
+}
+
+// commitTestFile writes the file and commits it
+func commitTestFile(t *testing.T, repo *git.Repository, dir, name, content string) plumbing.Hash {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Add(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := w.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "butler", Email: "butler@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
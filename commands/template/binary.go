@@ -0,0 +1,110 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// sniffLen is the number of bytes which are inspected to detect binary content
+	sniffLen = 8000
+	// maxInvalidUTF8 is the ratio of invalid UTF-8 bytes up to which content is text
+	maxInvalidUTF8 = 0.3
+)
+
+// isBinary reports whether the content is binary
+func isBinary(dat []byte) bool {
+	binary, _ := detectBinary(dat)
+	return binary
+}
+
+// detectBinary sniffs the first bytes of the content and reports whether it's
+// binary and why
+func detectBinary(dat []byte) (bool, string) {
+	if len(dat) > sniffLen {
+		dat = dat[:sniffLen]
+	}
+	if len(dat) == 0 {
+		return false, "empty"
+	}
+
+	if bytes.IndexByte(dat, 0) != -1 {
+		return true, "contains NUL bytes"
+	}
+
+	contentType := http.DetectContentType(dat)
+	if !strings.HasPrefix(contentType, "text/") {
+		return true, fmt.Sprintf("content type %s", contentType)
+	}
+
+	invalid := 0
+	for i := 0; i < len(dat); {
+		r, size := utf8.DecodeRune(dat[i:])
+		if r == utf8.RuneError && size == 1 {
+			// a rune can be cut at the end of the sample
+			if !utf8.FullRune(dat[i:]) {
+				break
+			}
+			invalid++
+		}
+		i += size
+	}
+	ratio := float64(invalid) / float64(len(dat))
+	if ratio > maxInvalidUTF8 {
+		return true, fmt.Sprintf("%.0f%% invalid UTF-8", ratio*100)
+	}
+
+	return false, fmt.Sprintf("content type %s", contentType)
+}
+
+// detectFileType reports whether the file is binary and why. The extensions of the
+// template take precedence over the list of binary extensions, the content is only
+// sniffed for other files.
+func (t *Templating) detectFileType(path string) (bool, string, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+
+	if t.templateConfig != nil && ext != "" {
+		if containsExtension(t.templateConfig.TextExtensions, ext) {
+			return false, fmt.Sprintf("text extension '.%s' of the template", ext), nil
+		}
+		if containsExtension(t.templateConfig.BinaryExtensions, ext) {
+			return true, fmt.Sprintf("binary extension '.%s' of the template", ext), nil
+		}
+	}
+
+	if _, ok := t.excludedExts[ext]; ok && ext != "" {
+		return true, fmt.Sprintf("known binary extension '.%s'", ext), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, "", err
+	}
+	defer f.Close()
+
+	dat := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, dat)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, "", err
+	}
+
+	binary, reason := detectBinary(dat[:n])
+	return binary, reason, nil
+}
+
+// containsExtension reports whether the extension is in the list. The extensions
+// of the list may start with a dot and are compared case-insensitive.
+func containsExtension(list []string, ext string) bool {
+	for _, e := range list {
+		if strings.ToLower(strings.TrimPrefix(e, ".")) == ext {
+			return true
+		}
+	}
+	return false
+}
@@ -6,117 +6,163 @@ import (
 	survey "gopkg.in/AlecAivazis/survey.v1"
 )
 
-// BuildSurvey generates a list of survey questions based on the template survey
-func BuildSurvey(s *Survey) ([]*survey.Question, error) {
+// BuildSurvey generates a list of survey questions based on the template survey.
+// The answers map is used to validate the answers against the previous answers.
+func BuildSurvey(s *Survey, answers map[string]interface{}, valid AnswerValidator) ([]*survey.Question, error) {
 	var qs []*survey.Question
 	for _, question := range s.Questions {
-		switch question.Type {
-		case "input":
-			p := &survey.Input{
-				Message: question.Message,
-				Help:    question.Help,
-			}
-			if question.Default != nil {
-				defaultValue, ok := question.Default.(string)
-				if !ok {
-					return nil, fmt.Errorf("default value must be a string on input questions")
-				}
-				p.Default = defaultValue
-			}
-			sqs := &survey.Question{
-				Name:   question.Name,
-				Prompt: p,
-			}
-			if question.Required {
-				sqs.Validate = survey.Required
-			}
-			qs = append(qs, sqs)
-		case "password":
-			p := &survey.Password{
-				Message: question.Message,
-				Help:    question.Help,
-			}
-			sqs := &survey.Question{
-				Name:   question.Name,
-				Prompt: p,
-			}
-			if question.Required {
-				sqs.Validate = survey.Required
+		sqs, err := buildQuestion(question, answers, valid)
+		if err != nil {
+			return nil, err
+		}
+		qs = append(qs, sqs)
+	}
+
+	return qs, nil
+}
+
+// buildQuestion generates the survey question of a single template question
+func buildQuestion(question Question, answers map[string]interface{}, valid AnswerValidator) (*survey.Question, error) {
+	switch question.Type {
+	case "input":
+		p := &survey.Input{
+			Message: question.Message,
+			Help:    question.Help,
+		}
+		if question.Default != nil {
+			defaultValue, ok := question.Default.(string)
+			if !ok {
+				return nil, fmt.Errorf("default value must be a string on input questions")
 			}
-			qs = append(qs, sqs)
-		case "confirm":
-			p := &survey.Confirm{
-				Message: question.Message,
-				Help:    question.Help,
+			p.Default = defaultValue
+		}
+		sqs := &survey.Question{
+			Name:   question.Name,
+			Prompt: p,
+		}
+		if question.Required || question.hasRules() {
+			sqs.Validate = questionValidator(question, answers, valid)
+		}
+		return sqs, nil
+	case "password":
+		p := &survey.Password{
+			Message: question.Message,
+			Help:    question.Help,
+		}
+		sqs := &survey.Question{
+			Name:   question.Name,
+			Prompt: p,
+		}
+		if question.Required || question.hasRules() {
+			sqs.Validate = questionValidator(question, answers, valid)
+		}
+		return sqs, nil
+	case "confirm":
+		p := &survey.Confirm{
+			Message: question.Message,
+			Help:    question.Help,
+		}
+		sqs := &survey.Question{
+			Name:   question.Name,
+			Prompt: p,
+		}
+		if question.Default != nil {
+			defaultValue, ok := question.Default.(bool)
+			if !ok {
+				return nil, fmt.Errorf("default value must be a boolean on confirm questions")
 			}
-			sqs := &survey.Question{
-				Name:   question.Name,
-				Prompt: p,
+			p.Default = defaultValue
+		}
+		if question.Required || question.hasRules() {
+			sqs.Validate = questionValidator(question, answers, valid)
+		}
+		return sqs, nil
+	case "select":
+		p := &survey.Select{
+			Message: question.Message,
+			Options: question.Options,
+			Help:    question.Help,
+		}
+		if question.Default != nil {
+			defaultValue, ok := question.Default.(string)
+			if !ok {
+				return nil, fmt.Errorf("default value must be a string on select questions")
 			}
-			if question.Default != nil {
-				defaultValue, ok := question.Default.(bool)
-				if !ok {
-					return nil, fmt.Errorf("default value must be a boolean on confirm questions")
+			p.Default = defaultValue
+		}
+		sqs := &survey.Question{
+			Name:   question.Name,
+			Prompt: p,
+		}
+		if question.Required || question.hasRules() {
+			sqs.Validate = questionValidator(question, answers, valid)
+		}
+		return sqs, nil
+	case "multiselect":
+		p := &survey.MultiSelect{
+			Message: question.Message,
+			Options: question.Options,
+			Help:    question.Help,
+		}
+		defaults := []string{}
+		if question.Default != nil {
+			defaultValue, ok := question.Default.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("default value must be an array of strings on multiselect questions")
+			}
+			for _, v := range defaultValue {
+				s, ok := v.(string)
+				if ok {
+					defaults = append(defaults, s)
 				}
-				p.Default = defaultValue
-			}
-			if question.Required {
-				sqs.Validate = survey.Required
-			}
-			qs = append(qs, sqs)
-		case "select":
-			p := &survey.Select{
-				Message: question.Message,
-				Options: question.Options,
-				Help:    question.Help,
 			}
+		}
+		p.Default = defaults
+		sqs := &survey.Question{
+			Name:   question.Name,
+			Prompt: p,
+		}
+		if question.Required || question.hasRules() {
+			sqs.Validate = questionValidator(question, answers, valid)
+		}
+		return sqs, nil
+	case "number", "path", "editor", "list", "map":
+		defaultValue, err := normalizeAnswer(question, question.Default)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default value on %s questions: %s", question.Type, err)
+		}
+		input := survey.Input{
+			Message: question.Message,
+			Help:    question.Help,
+		}
+		var p survey.Prompt
+		switch question.Type {
+		case "number":
 			if question.Default != nil {
-				defaultValue, ok := question.Default.(string)
-				if !ok {
-					return nil, fmt.Errorf("default value must be a string on select questions")
-				}
-				p.Default = defaultValue
-			}
-			sqs := &survey.Question{
-				Name:   question.Name,
-				Prompt: p,
-			}
-			if question.Required {
-				sqs.Validate = survey.Required
-			}
-			qs = append(qs, sqs)
-		case "multiselect":
-			p := &survey.MultiSelect{
+				input.Default = fmt.Sprint(defaultValue)
+			}
+			p = &input
+		case "path":
+			input.Default = defaultValue.(string)
+			p = &pathPrompt{input}
+		case "editor":
+			p = &survey.Editor{
 				Message: question.Message,
-				Options: question.Options,
 				Help:    question.Help,
+				Default: defaultValue.(string),
 			}
-			defaults := []string{}
-			if question.Default != nil {
-				defaultValue, ok := question.Default.([]interface{})
-				if !ok {
-					return nil, fmt.Errorf("default value must be an array of strings on multiselect questions")
-				}
-				for _, v := range defaultValue {
-					s, ok := v.(string)
-					if ok {
-						defaults = append(defaults, s)
-					}
-				}
-			}
-			p.Default = defaults
-			sqs := &survey.Question{
-				Name:   question.Name,
-				Prompt: p,
-			}
-			if question.Required {
-				sqs.Validate = survey.Required
-			}
-			qs = append(qs, sqs)
-		default:
-			return nil, fmt.Errorf("invalid prompt type %s", question.Type)
+		case "list":
+			p = &listPrompt{input, defaultValue.([]string)}
+		case "map":
+			p = &mapPrompt{input, defaultValue.(map[string]string)}
 		}
+		// the validator converts the answers and checks paths and numbers
+		return &survey.Question{
+			Name:     question.Name,
+			Prompt:   p,
+			Validate: questionValidator(question, answers, valid),
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid prompt type %s", question.Type)
 	}
-
-	return qs, nil
 }
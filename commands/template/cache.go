@@ -0,0 +1,232 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	logy "github.com/apex/log"
+	"github.com/netzkern/butler/config"
+	"github.com/netzkern/butler/utils"
+	"github.com/pkg/errors"
+	git "gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const cacheMetaFilename = "butler-cache.yml"
+
+// mirrorRefSpecs fetches every ref of the remote like git clone --mirror
+var mirrorRefSpecs = []gitconfig.RefSpec{
+	"+refs/*:refs/*",
+}
+
+type (
+	// Cache stores bare mirrors of template repositories to avoid a full clone on
+	// every run and to work offline
+	Cache struct {
+		dir     string
+		offline bool
+	}
+	// CacheEntry represents a mirror in the cache
+	CacheEntry struct {
+		Key       string    `yaml:"-"`
+		Dir       string    `yaml:"-"`
+		URL       string    `yaml:"url"`
+		UpdatedAt time.Time `yaml:"updatedAt"`
+	}
+)
+
+// NewCache creates a cache in the directory. In offline mode the mirrors are never fetched.
+func NewCache(dir string, offline bool) *Cache {
+	return &Cache{
+		dir:     dir,
+		offline: offline,
+	}
+}
+
+// DefaultCacheDir returns the cache directory in the butler directory of the user config dir
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "user config dir")
+	}
+	return filepath.Join(dir, "butler", "cache"), nil
+}
+
+// cacheKey returns the directory name of the template. All refs of a repository
+// share the mirror because every fetch updates all refs.
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// mirror returns the directory of an up-to-date mirror of the repository. When the
+// repository can't be fetched the cached mirror is used. It reports whether the mirror
+// was already cached. The auth method is only resolved when the remote is accessed.
+func (c *Cache) mirror(url string, authMethod func() (transport.AuthMethod, error)) (string, bool, error) {
+	entry := &CacheEntry{
+		Key: cacheKey(url),
+		URL: url,
+	}
+	entry.Dir = filepath.Join(c.dir, entry.Key)
+	ctx := logy.WithFields(logy.Fields{
+		"url":   url,
+		"cache": entry.Dir,
+	})
+
+	if utils.Exists(filepath.Join(entry.Dir, cacheMetaFilename)) {
+		if c.offline {
+			ctx.Debug("offline, use cached template")
+			return entry.Dir, true, nil
+		}
+
+		auth, err := authMethod()
+		if err == nil {
+			err = c.fetch(entry, auth)
+		}
+		if err != nil {
+			ctx.WithError(err).Warn("could not update template, use cached template")
+		}
+
+		return entry.Dir, true, nil
+	}
+
+	if c.offline {
+		return "", false, errors.Errorf("template '%s' isn't cached and can't be cloned in offline mode", url)
+	}
+
+	auth, err := authMethod()
+	if err != nil {
+		return "", false, err
+	}
+
+	ctx.Debug("create mirror")
+
+	err = utils.CreateDirIfNotExist(entry.Dir)
+	if err != nil {
+		return "", false, errors.Wrap(err, "create cache dir")
+	}
+
+	repo, err := git.PlainInit(entry.Dir, true)
+	if err == nil {
+		_, err = repo.CreateRemote(&gitconfig.RemoteConfig{
+			Name:  git.DefaultRemoteName,
+			URLs:  []string{url},
+			Fetch: mirrorRefSpecs,
+		})
+	}
+	if err == nil {
+		err = c.fetch(entry, auth)
+	}
+	if err != nil {
+		os.RemoveAll(entry.Dir)
+		return "", false, err
+	}
+
+	return entry.Dir, false, nil
+}
+
+// fetch updates all refs and the default branch of the mirror
+func (c *Cache) fetch(entry *CacheEntry, auth transport.AuthMethod) error {
+	repo, err := git.PlainOpen(entry.Dir)
+	if err != nil {
+		return errors.Wrap(err, "open mirror")
+	}
+
+	err = repo.Fetch(&git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		RefSpecs:   mirrorRefSpecs,
+		Auth:       auth,
+		Tags:       git.AllTags,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return gitError(entry.URL, err)
+	}
+
+	// point HEAD to the default branch of the remote
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return errors.Wrap(err, "remote")
+	}
+	refs, err := remote.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		return gitError(entry.URL, err)
+	}
+	for _, r := range refs {
+		if r.Name() == plumbing.HEAD && r.Type() == plumbing.SymbolicReference {
+			err = repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, r.Target()))
+			if err != nil {
+				return errors.Wrap(err, "set head")
+			}
+		}
+	}
+
+	entry.UpdatedAt = time.Now()
+	dat, err := yaml.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(entry.Dir, cacheMetaFilename), dat, 0644)
+}
+
+// Entries returns all cached templates
+func (c *Cache) Entries() ([]*CacheEntry, error) {
+	entries := []*CacheEntry{}
+
+	if !utils.Exists(c.dir) {
+		return entries, nil
+	}
+
+	dirs, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read cache dir")
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		dat, err := ioutil.ReadFile(filepath.Join(c.dir, dir.Name(), cacheMetaFilename))
+		if err != nil {
+			logy.WithError(err).Warnf("invalid cache entry '%s'", dir.Name())
+			continue
+		}
+		entry := &CacheEntry{}
+		err = yaml.Unmarshal(dat, entry)
+		if err != nil {
+			logy.WithError(err).Warnf("invalid cache entry '%s'", dir.Name())
+			continue
+		}
+		entry.Key = dir.Name()
+		entry.Dir = filepath.Join(c.dir, dir.Name())
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].URL < entries[j].URL
+	})
+
+	return entries, nil
+}
+
+// Refresh fetches the latest changes of all refs of the cached template
+func (c *Cache) Refresh(entry *CacheEntry, tplAuth, globalAuth *config.GitAuth) error {
+	auth, err := gitAuthMethod(entry.URL, tplAuth, globalAuth)
+	if err != nil {
+		return err
+	}
+	return c.fetch(entry, auth)
+}
+
+// Remove deletes the cached template
+func (c *Cache) Remove(entry *CacheEntry) error {
+	return os.RemoveAll(entry.Dir)
+}
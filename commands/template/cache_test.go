@@ -0,0 +1,152 @@
+package template
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+func TestCacheMirror(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	repoDir, repo := initTestRepo(t)
+	defer os.RemoveAll(repoDir)
+	commitTestFile(t, repo, repoDir, "a.txt", "v1")
+
+	cacheDir, err := ioutil.TempDir("", "butler-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	url := "file://" + repoDir
+	calls := 0
+	noAuth := func() (transport.AuthMethod, error) {
+		calls++
+		return nil, nil
+	}
+	failingAuth := func() (transport.AuthMethod, error) {
+		calls++
+		return nil, errors.New("no ssh agent")
+	}
+
+	// not cached in offline mode
+	_, _, err = NewCache(cacheDir, true).mirror(url, failingAuth)
+	if err == nil {
+		t.Fatal("expected an error for a template which isn't cached in offline mode")
+	}
+
+	dir, hit, err := NewCache(cacheDir, false).mirror(url, noAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hit {
+		t.Error("expected a cache miss")
+	}
+	if filepath.Base(dir) != cacheKey(url) {
+		t.Errorf("expected the mirror to be keyed by the url, got %s", dir)
+	}
+
+	// the credentials aren't resolved offline
+	calls = 0
+	offlineDir, hit, err := NewCache(cacheDir, true).mirror(url, failingAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hit || offlineDir != dir {
+		t.Errorf("expected a cache hit in %s, got %s", dir, offlineDir)
+	}
+	if calls != 0 {
+		t.Errorf("expected no credentials to be resolved offline, got %d calls", calls)
+	}
+
+	// the cached mirror is used when the credentials can't be resolved
+	onlineDir, hit, err := NewCache(cacheDir, false).mirror(url, failingAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hit || onlineDir != dir {
+		t.Errorf("expected a cache hit in %s, got %s", dir, onlineDir)
+	}
+
+	entries, err := NewCache(cacheDir, true).Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].URL != url {
+		t.Errorf("expected one entry for %s, got %+v", url, entries)
+	}
+}
+
+func TestCacheRefreshFetchesAllRefs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	repoDir, repo := initTestRepo(t)
+	defer os.RemoveAll(repoDir)
+	commitTestFile(t, repo, repoDir, "a.txt", "v1")
+
+	cacheDir, err := ioutil.TempDir("", "butler-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+
+	url := "file://" + repoDir
+	cache := NewCache(cacheDir, false)
+	_, _, err = cache.mirror(url, func() (transport.AuthMethod, error) { return nil, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// refs which are created after the mirror
+	hash := commitTestFile(t, repo, repoDir, "a.txt", "v2")
+	refs := []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName("feature"),
+		plumbing.NewTagReferenceName("v2.0.0"),
+		plumbing.ReferenceName("refs/review/1"),
+	}
+	for _, name := range refs {
+		err = repo.Storer.SetReference(plumbing.NewHashReference(name, hash))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %+v", entries)
+	}
+	err = cache.Refresh(entries[0], nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirror, err := git.PlainOpen(entries[0].Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range refs {
+		ref, err := mirror.Reference(name, false)
+		if err != nil {
+			t.Errorf("expected %s in the mirror: %v", name, err)
+			continue
+		}
+		if ref.Hash() != hash {
+			t.Errorf("expected %s to point to %s, got %s", name, hash, ref.Hash())
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	logy "github.com/apex/log"
+	"github.com/netzkern/butler/utils"
+	"github.com/pkg/errors"
+)
+
+// checkout writes a rendered project to the destination and can undo it. A new
+// destination is staged in a sibling directory and renamed at once. When the
+// destination already has files the created and replaced paths are journaled.
+type checkout struct {
+	dest string
+	// the destination didn't exist or was an empty directory
+	fresh bool
+	// the empty destination directory which existed before
+	emptyDir os.FileInfo
+	// paths relative to dest in the order they were created
+	created []string
+	// paths relative to dest which were replaced, the old versions are in backupDir
+	replaced  []string
+	backupDir string
+	opts      []utils.CopyOption
+}
+
+// newCheckout inspects the destination. The options apply to the files of the project.
+func newCheckout(dest string, opts ...utils.CopyOption) (*checkout, error) {
+	c := &checkout{dest: dest, opts: opts}
+
+	info, err := os.Stat(dest)
+	if os.IsNotExist(err) {
+		c.fresh = true
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.Errorf("destination '%s' isn't a directory", dest)
+	}
+
+	entries, err := ioutil.ReadDir(dest)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		c.fresh = true
+		c.emptyDir = info
+	}
+
+	return c, nil
+}
+
+// write copies the files of src into the destination. The destination is
+// restored when the copy fails.
+func (c *checkout) write(src string, conflicts []*fileConflict) error {
+	if c.fresh {
+		return c.writeFresh(src)
+	}
+
+	logy.Debugf("merge project into existing directory %s", c.dest)
+
+	err := copyProject(src, c.dest, conflicts, c, c.opts...)
+	if err != nil {
+		if rerr := c.rollback(); rerr != nil {
+			logy.WithError(rerr).Error("rollback failed")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// writeFresh stages the project next to the destination so the rename stays on the
+// same filesystem and the destination appears complete or not at all
+func (c *checkout) writeFresh(src string) error {
+	parent := filepath.Dir(c.dest)
+	err := utils.CreateDirIfNotExist(parent)
+	if err != nil {
+		return errors.Wrap(err, "create parent dir failed")
+	}
+
+	staging, err := ioutil.TempDir(parent, "."+filepath.Base(c.dest)+".butler-")
+	if err != nil {
+		return errors.Wrap(err, "create staging dir failed")
+	}
+	logy.Debugf("stage project in %s", staging)
+
+	mode := os.FileMode(0755)
+	if c.emptyDir != nil {
+		mode = c.emptyDir.Mode().Perm()
+	}
+
+	err = copyProject(src, staging, nil, nil, c.opts...)
+	if err == nil {
+		err = os.Chmod(staging, mode)
+	}
+	if err == nil && c.emptyDir != nil {
+		err = os.Remove(c.dest)
+	}
+	if err == nil {
+		err = os.Rename(staging, c.dest)
+	}
+	if err != nil {
+		os.RemoveAll(staging)
+		c.restoreEmptyDir()
+		return err
+	}
+
+	return nil
+}
+
+// record is called before the path of the destination is written. New paths are
+// journaled and existing paths are backed up when they are replaced.
+func (c *checkout) record(rel string, replace bool) error {
+	if c == nil || rel == "." {
+		return nil
+	}
+
+	target := filepath.Join(c.dest, rel)
+	_, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		c.created = append(c.created, rel)
+		return nil
+	}
+	if err != nil || !replace {
+		return err
+	}
+
+	if c.backupDir == "" {
+		c.backupDir, err = ioutil.TempDir("", "butler-backup")
+		if err != nil {
+			return errors.Wrap(err, "create backup dir failed")
+		}
+	}
+
+	err = copyPath(target, filepath.Join(c.backupDir, rel))
+	if err != nil {
+		return errors.Wrapf(err, "backup '%s'", rel)
+	}
+	c.replaced = append(c.replaced, rel)
+
+	return nil
+}
+
+// rollback removes the created paths and restores the replaced paths
+func (c *checkout) rollback() error {
+	logy.Infof("roll back checkout of '%s'", c.dest)
+
+	if c.fresh {
+		err := os.RemoveAll(c.dest)
+		if err != nil {
+			return errors.Wrap(err, "remove destination")
+		}
+		c.restoreEmptyDir()
+		return nil
+	}
+
+	var failed []string
+	for i := len(c.created) - 1; i >= 0; i-- {
+		err := os.RemoveAll(filepath.Join(c.dest, c.created[i]))
+		if err != nil {
+			logy.WithError(err).Errorf("remove '%s'", c.created[i])
+			failed = append(failed, c.created[i])
+		}
+	}
+	for i := len(c.replaced) - 1; i >= 0; i-- {
+		rel := c.replaced[i]
+		target := filepath.Join(c.dest, rel)
+		err := os.RemoveAll(target)
+		if err == nil {
+			err = copyPath(filepath.Join(c.backupDir, rel), target)
+		}
+		if err != nil {
+			logy.WithError(err).Errorf("restore '%s'", rel)
+			failed = append(failed, rel)
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("%d paths could not be restored, the backup is kept in '%s'", len(failed), c.backupDir)
+	}
+
+	return c.clean()
+}
+
+// clean removes the backups of the replaced paths
+func (c *checkout) clean() error {
+	if c.backupDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(c.backupDir)
+	if err != nil {
+		return errors.Wrap(err, "remove backup failed")
+	}
+	c.backupDir = ""
+
+	return nil
+}
+
+// restoreEmptyDir recreates the empty destination directory
+func (c *checkout) restoreEmptyDir() {
+	if c.emptyDir == nil {
+		return
+	}
+	err := os.MkdirAll(c.dest, c.emptyDir.Mode().Perm())
+	if err != nil {
+		logy.WithError(err).Errorf("restore directory '%s'", c.dest)
+	}
+}
+
+// copyPath copies a file, symlink or directory with its modification times to a new path
+func copyPath(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(dst), 0755)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.IsDir():
+		return utils.CopyDir(src, dst, utils.WithPreserveTimes(true))
+	case info.Mode()&os.ModeSymlink != 0:
+		return utils.CopySymlink(src, dst)
+	}
+
+	return utils.CopyFile(src, dst, utils.WithPreserveTimes(true))
+}
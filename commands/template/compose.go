@@ -0,0 +1,248 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	logy "github.com/apex/log"
+	"github.com/netzkern/butler/config"
+	"github.com/netzkern/butler/utils"
+	"github.com/pkg/errors"
+)
+
+// templateKey identifies a template source to detect cyclic compositions
+func templateKey(tpl *config.Template) string {
+	return tpl.URL + "@" + tpl.Ref + "#" + filepath.Clean(tpl.Path)
+}
+
+// compose fetches the base templates of the survey and merges them into the
+// template in dir. The files of the template win over the files of the layers,
+// the layers win over the base template of extends and later layers win over
+// earlier layers. The same applies to questions, hooks and variables.
+func (t *Templating) compose(s *Survey, dir string, parents map[string]struct{}) (*Survey, error) {
+	bases := []config.Template{}
+	if s.Extends != nil {
+		bases = append(bases, *s.Extends)
+	}
+	bases = append(bases, s.Layers...)
+	if len(bases) == 0 {
+		return s, nil
+	}
+
+	merged := &Survey{}
+	layerDirs := make([]string, 0, len(bases))
+	for i := range bases {
+		base := &bases[i]
+		ctx := logy.WithFields(logy.Fields{
+			"url":  base.URL,
+			"ref":  base.Ref,
+			"path": base.Path,
+		})
+
+		key := templateKey(base)
+		if _, ok := parents[key]; ok {
+			return nil, errors.Errorf("cyclic composition, template '%s' extends itself", base.URL)
+		}
+
+		layerDir, err := ioutil.TempDir("", "butler")
+		if err != nil {
+			return nil, errors.Wrap(err, "create temp folder failed")
+		}
+		defer os.RemoveAll(layerDir)
+
+		err = t.unpackLayer(base, layerDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "base template '%s'", base.URL)
+		}
+
+		layer := &Survey{}
+		configPath := filepath.Join(layerDir, t.configName)
+		if utils.Exists(configPath) {
+			layer, err = ReadSurveyConfig(configPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "base template '%s'", base.URL)
+			}
+			err = t.checkCompatibility(layer, ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// the bases of the layer are merged before the layer is merged into the template
+		layerParents := map[string]struct{}{key: {}}
+		for k := range parents {
+			layerParents[k] = struct{}{}
+		}
+		layer, err = t.compose(layer, layerDir, layerParents)
+		if err != nil {
+			return nil, err
+		}
+
+		// the test cases of the layer aren't part of the template, the directory
+		// may differ from the one of the template
+		err = removeTests(layer, layerDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "base template '%s'", base.URL)
+		}
+
+		// the ignored files of the layer are excluded from the merged template
+		ignored, err := readIgnoreFile(filepath.Join(layerDir, IgnoreFilename))
+		if err != nil {
+			return nil, err
+		}
+		layer.Exclude = append(ignored, layer.Exclude...)
+
+		ctx.Debug("merge base template")
+		merged = mergeSurveys(merged, layer)
+		layerDirs = append(layerDirs, layerDir)
+	}
+
+	// files which already exist aren't overwritten so the layer with the
+	// highest priority is copied first
+	for i := len(layerDirs) - 1; i >= 0; i-- {
+		err := t.overlayDir(layerDirs[i], dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "merge base template '%s'", bases[i].URL)
+		}
+	}
+
+	return mergeSurveys(merged, s), nil
+}
+
+// unpackLayer unpacks a base template. The revision of an update only applies to
+// the template itself and the commit and checksum of the template are kept.
+func (t *Templating) unpackLayer(tpl *config.Template, dest string) error {
+	err := validateTemplatePath(tpl.Path)
+	if err != nil {
+		return err
+	}
+
+	commit, checksum, revision := t.commit, t.checksum, t.revision
+	defer func() {
+		t.commit, t.checksum, t.revision = commit, checksum, revision
+	}()
+	t.revision = ""
+
+	return t.unpack(tpl, dest)
+}
+
+// overlayDir copies the files of src to dst which don't exist in dst. The survey
+// config and the ignore file of the root are skipped because they are merged separately.
+func (t *Templating) overlayDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "walk failed")
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == t.configName || rel == IgnoreFilename {
+			return nil
+		}
+
+		target := filepath.Join(dst, rel)
+		existing, err := os.Lstat(target)
+		if err == nil {
+			// the file or directory of the template wins
+			if info.IsDir() && !existing.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			return utils.CopySymlink(path, target)
+		case info.Mode().IsRegular():
+			return utils.CopyFile(path, target, t.copyOptions()...)
+		}
+
+		return nil
+	})
+}
+
+// mergeSurveys merges the child survey into the base survey. Questions, hooks
+// and generators of the child replace the ones with the same name at their position.
+func mergeSurveys(base, child *Survey) *Survey {
+	merged := &Survey{
+		ButlerVersion: child.ButlerVersion,
+		Deprecated:    child.Deprecated,
+		Partials:      base.Partials,
+		Variables:     map[string]interface{}{},
+	}
+
+	merged.Questions = append(merged.Questions, base.Questions...)
+	for _, q := range child.Questions {
+		replaced := false
+		for i := range merged.Questions {
+			if merged.Questions[i].Name == q.Name {
+				merged.Questions[i] = q
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged.Questions = append(merged.Questions, q)
+		}
+	}
+
+	merged.AfterHooks = append(merged.AfterHooks, base.AfterHooks...)
+	for _, h := range child.AfterHooks {
+		replaced := false
+		for i := range merged.AfterHooks {
+			if merged.AfterHooks[i].Name == h.Name {
+				merged.AfterHooks[i] = h
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged.AfterHooks = append(merged.AfterHooks, h)
+		}
+	}
+
+	for k, v := range base.Variables {
+		merged.Variables[k] = v
+	}
+	for k, v := range child.Variables {
+		merged.Variables[k] = v
+	}
+
+	if child.Partials != "" {
+		merged.Partials = child.Partials
+	}
+	merged.Tests = child.Tests
+
+	merged.Generators = append(merged.Generators, base.Generators...)
+	for _, g := range child.Generators {
+		replaced := false
+		for i := range merged.Generators {
+			if merged.Generators[i].Name == g.Name {
+				merged.Generators[i] = g
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged.Generators = append(merged.Generators, g)
+		}
+	}
+
+	// the rules of the child are matched first
+	merged.Conflicts = append(append([]ConflictRule{}, child.Conflicts...), base.Conflicts...)
+	merged.Exclude = append(append([]string{}, base.Exclude...), child.Exclude...)
+	merged.CopyOnly = append(append([]string{}, base.CopyOnly...), child.CopyOnly...)
+	merged.Include = append(append([]string{}, base.Include...), child.Include...)
+	merged.BinaryExtensions = append(append([]string{}, base.BinaryExtensions...), child.BinaryExtensions...)
+	merged.TextExtensions = append(append([]string{}, base.TextExtensions...), child.TextExtensions...)
+
+	return merged
+}
@@ -2,8 +2,10 @@ package template
 
 import (
 	"io/ioutil"
+	"regexp"
 
 	logy "github.com/apex/log"
+	"github.com/netzkern/butler/config"
 	validator "gopkg.in/go-playground/validator.v9"
 	yaml "gopkg.in/yaml.v2"
 )
@@ -17,6 +19,27 @@ type Question struct {
 	Message  string      `json:"message" validate:"required"`
 	Required bool        `json:"required"`
 	Help     string      `json:"help"`
+	When     string      `json:"when"`
+	// validation rules
+	Pattern         string   `json:"pattern"`
+	MinLength       *int     `json:"minLength" yaml:"minLength"`
+	MaxLength       *int     `json:"maxLength" yaml:"maxLength"`
+	Min             *float64 `json:"min"`
+	Max             *float64 `json:"max"`
+	OneOf           []string `json:"oneOf" yaml:"oneOf"`
+	Validate        string   `json:"validate"`
+	ValidateMessage string   `json:"validateMessage" yaml:"validateMessage"`
+	MustExist       bool     `json:"mustExist" yaml:"mustExist"`
+	Directory       bool     `json:"directory"`
+	// produces additional options right before the question is asked
+	OptionsFrom *OptionsSource `json:"optionsFrom" yaml:"optionsFrom"`
+}
+
+// OptionsSource represents a command or a file of the template which produces one option per line
+type OptionsSource struct {
+	Cmd  string   `json:"cmd"`
+	Args []string `json:"args"`
+	File string   `json:"file"`
 }
 
 // Hook represent a hook in the yml file
@@ -31,11 +54,46 @@ type Hook struct {
 
 // Survey represents in the yml file
 type Survey struct {
-	Questions     []Question             `yaml:"questions" validate:"required,dive"`
+	Questions     []Question             `yaml:"questions" validate:"dive"`
 	AfterHooks    []Hook                 `yaml:"afterHooks"`
 	Variables     map[string]interface{} `yaml:"variables"`
 	ButlerVersion string                 `yaml:"butlerVersion"`
 	Deprecated    bool                   `yaml:"deprecated"`
+	Exclude       []string               `yaml:"exclude"`
+	CopyOnly      []string               `yaml:"copyOnly"`
+	Include       []string               `yaml:"include"`
+	Partials      string                 `yaml:"partials"`
+	// directory of the test cases
+	Tests string `yaml:"tests"`
+	// extensions which override the binary detection
+	BinaryExtensions []string `yaml:"binaryExtensions"`
+	TextExtensions   []string `yaml:"textExtensions"`
+	// base templates
+	Extends *config.Template  `yaml:"extends"`
+	Layers  []config.Template `yaml:"layers"`
+	// generators which add files to existing projects
+	Generators []Generator `yaml:"generators" validate:"dive"`
+	// conflict policies of existing files in the destination
+	Conflicts []ConflictRule `yaml:"conflicts" validate:"dive"`
+}
+
+// ConflictRule represents the conflict policy of the files which match the gitignore pattern
+type ConflictRule struct {
+	Pattern string `yaml:"pattern" validate:"required"`
+	Policy  string `yaml:"policy" validate:"required,oneof=abort skip overwrite prompt merge-diff"`
+}
+
+// Generator represents a named generator with its own questions and files
+type Generator struct {
+	Name        string                 `yaml:"name" validate:"required"`
+	Description string                 `yaml:"description"`
+	Path        string                 `yaml:"path"`
+	Questions   []Question             `yaml:"questions" validate:"dive"`
+	AfterHooks  []Hook                 `yaml:"afterHooks"`
+	Variables   map[string]interface{} `yaml:"variables"`
+	Exclude     []string               `yaml:"exclude"`
+	CopyOnly    []string               `yaml:"copyOnly"`
+	Include     []string               `yaml:"include"`
 }
 
 // ReadSurveyConfig reads the config and return a new survey
@@ -65,13 +123,81 @@ func ReadSurveyConfig(path string) (*Survey, error) {
 func validate(cfg interface{}) error {
 	validate := validator.New()
 	validate.RegisterStructValidation(questionStructHasOptions, Question{})
+	validate.RegisterStructValidation(questionStructHasValidRules, Question{})
+	validate.RegisterStructValidation(surveyStructHasQuestions, Survey{})
 	return validate.Struct(cfg)
 }
 
+// surveyStructHasQuestions requires questions unless they are inherited from the base templates
+func surveyStructHasQuestions(sl validator.StructLevel) {
+	s := sl.Current().Interface().(Survey)
+
+	if s.Questions == nil && s.Extends == nil && len(s.Layers) == 0 {
+		sl.ReportError(s.Questions, "questions", "Questions", "required", "")
+	}
+}
+
 func questionStructHasOptions(sl validator.StructLevel) {
 	question := sl.Current().Interface().(Question)
 
-	if (question.Type == "select" || question.Type == "multiselect") && len(question.Options) == 0 {
+	if (question.Type == "select" || question.Type == "multiselect") && len(question.Options) == 0 && question.OptionsFrom == nil {
 		sl.ReportError(question.Options, "options", "foptions", "optionsRequired", "")
 	}
+
+	if src := question.OptionsFrom; src != nil {
+		if question.Type != "select" && question.Type != "multiselect" {
+			sl.ReportError(question.OptionsFrom, "optionsFrom", "OptionsFrom", "optionsNotSupported", "")
+		}
+		if (src.Cmd == "") == (src.File == "") {
+			sl.ReportError(question.OptionsFrom, "optionsFrom", "OptionsFrom", "cmdOrFile", "")
+		}
+	}
+}
+
+func questionStructHasValidRules(sl validator.StructLevel) {
+	question := sl.Current().Interface().(Question)
+
+	if question.Pattern != "" {
+		if _, err := regexp.Compile(question.Pattern); err != nil {
+			sl.ReportError(question.Pattern, "pattern", "Pattern", "regexp", "")
+		}
+	}
+
+	if question.MinLength != nil && *question.MinLength < 0 {
+		sl.ReportError(question.MinLength, "minLength", "MinLength", "min", "0")
+	}
+	if question.MaxLength != nil && *question.MaxLength < 0 {
+		sl.ReportError(question.MaxLength, "maxLength", "MaxLength", "min", "0")
+	}
+	if question.MinLength != nil && question.MaxLength != nil && *question.MinLength > *question.MaxLength {
+		sl.ReportError(question.MaxLength, "maxLength", "MaxLength", "gtefield", "minLength")
+	}
+	if question.Min != nil && question.Max != nil && *question.Min > *question.Max {
+		sl.ReportError(question.Max, "max", "Max", "gtefield", "min")
+	}
+
+	// the declarative rules aren't applicable to booleans
+	if question.Type == "confirm" && question.hasDeclarativeRules() {
+		sl.ReportError(question.Type, "type", "Type", "rulesNotSupported", "")
+	}
+	if (question.Min != nil || question.Max != nil) && (question.Type == "select" || question.Type == "multiselect") {
+		sl.ReportError(question.Type, "type", "Type", "numberRulesNotSupported", "")
+	}
+
+	for _, v := range question.OneOf {
+		if len(question.Options) > 0 && !containsString(question.Options, v) {
+			sl.ReportError(question.OneOf, "oneOf", "OneOf", "options", v)
+		}
+	}
+
+	if (question.MustExist || question.Directory) && question.Type != "path" {
+		sl.ReportError(question.Type, "type", "Type", "pathRulesNotSupported", "")
+	}
+	if question.Type == "number" && (question.Pattern != "" || question.MinLength != nil || question.MaxLength != nil) {
+		sl.ReportError(question.Type, "type", "Type", "textRulesNotSupported", "")
+	}
+
+	if question.ValidateMessage != "" && question.Validate == "" {
+		sl.ReportError(question.Validate, "validate", "Validate", "required_with", "validateMessage")
+	}
 }
@@ -0,0 +1,358 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	logy "github.com/apex/log"
+	"github.com/netzkern/butler/utils"
+	"github.com/pkg/errors"
+	survey "gopkg.in/AlecAivazis/survey.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+)
+
+// Conflict policies decide what happens with files which already exist in the
+// destination with a different content
+const (
+	// the project isn't written
+	ConflictAbort = "abort"
+	// the existing file is kept
+	ConflictSkip = "skip"
+	// the existing file is replaced
+	ConflictOverwrite = "overwrite"
+	// the user decides for every file
+	ConflictPrompt = "prompt"
+	// the lines of both files are merged, differing lines are wrapped in conflict markers
+	ConflictMergeDiff = "merge-diff"
+)
+
+// ConflictPolicies are all valid conflict policies
+var ConflictPolicies = []string{ConflictAbort, ConflictSkip, ConflictOverwrite, ConflictPrompt, ConflictMergeDiff}
+
+// fileConflict is a path of the template which already exists in the destination
+type fileConflict struct {
+	path   string
+	policy string
+	// a file and a directory have the same path
+	typeClash bool
+	// the template or the destination has a symlink with a different target
+	symlink bool
+}
+
+// conflictPolicy returns the policy of the run. Without a policy the user is asked
+// in interactive mode, otherwise the run is aborted.
+func (t *Templating) conflictPolicy() string {
+	if t.conflict != "" {
+		return t.conflict
+	}
+	if t.interactive {
+		return ConflictPrompt
+	}
+	return ConflictAbort
+}
+
+// detectConflicts returns all paths of src which exist in dest with a different
+// content or type. The policy of the first matching rule of the template applies,
+// otherwise the policy of the run.
+func (t *Templating) detectConflicts(src, dest string) ([]*fileConflict, error) {
+	if t.conflict != "" && !containsString(ConflictPolicies, t.conflict) {
+		return nil, errors.Errorf("invalid conflict policy '%s', expected one of [%s]", t.conflict, strings.Join(ConflictPolicies, ", "))
+	}
+	if !utils.Exists(dest) {
+		return nil, nil
+	}
+
+	type rule struct {
+		matcher gitignore.Matcher
+		policy  string
+	}
+	rules := []rule{}
+	if t.templateConfig != nil {
+		for _, r := range t.templateConfig.Conflicts {
+			rules = append(rules, rule{newMatcher([]string{r.Pattern}), r.Policy})
+		}
+	}
+
+	conflicts := []*fileConflict{}
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "walk failed")
+		}
+		if path == src {
+			return nil
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		existing, err := os.Lstat(filepath.Join(dest, rel))
+		if os.IsNotExist(err) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		c := &fileConflict{path: rel, policy: t.conflictPolicy()}
+		switch {
+		case info.IsDir() && existing.IsDir():
+			return nil
+		case info.IsDir() != existing.IsDir():
+			c.typeClash = true
+		case info.Mode()&os.ModeSymlink != 0 || existing.Mode()&os.ModeSymlink != 0:
+			if sameSymlink(path, filepath.Join(dest, rel)) {
+				return nil
+			}
+			c.symlink = true
+		default:
+			newDat, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			curDat, err := ioutil.ReadFile(filepath.Join(dest, rel))
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(newDat, curDat) {
+				return nil
+			}
+		}
+
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		for _, r := range rules {
+			if r.matcher.Match(parts, info.IsDir()) {
+				c.policy = r.policy
+				break
+			}
+		}
+		conflicts = append(conflicts, c)
+
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+
+	return conflicts, err
+}
+
+// printConflicts lists the conflicts with their policies
+func printConflicts(output io.Writer, dest string, conflicts []*fileConflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	fmt.Fprintf(output, "Conflicting paths in '%s' (%d):\n", dest, len(conflicts))
+	for _, c := range conflicts {
+		name := filepath.ToSlash(c.path)
+		if c.typeClash {
+			name += " [file/directory]"
+		}
+		if c.symlink {
+			name += " [symlink]"
+		}
+		fmt.Fprintf(output, "  %s (%s)\n", name, c.policy)
+	}
+}
+
+// resolveConflicts asks the user for the conflicts with the prompt policy and
+// fails when a conflict has to abort the run
+func (t *Templating) resolveConflicts(src, dest string, conflicts []*fileConflict) error {
+	for _, c := range conflicts {
+		if c.policy == ConflictPrompt {
+			if !t.interactive {
+				c.policy = ConflictAbort
+				continue
+			}
+			policy, err := promptConflict(src, dest, c)
+			if err != nil {
+				return err
+			}
+			c.policy = policy
+		}
+		// paths with different types and symlinks can't be merged
+		if (c.typeClash || c.symlink) && c.policy == ConflictMergeDiff {
+			c.policy = ConflictAbort
+		}
+	}
+
+	aborted := []string{}
+	for _, c := range conflicts {
+		if c.policy == ConflictAbort {
+			aborted = append(aborted, filepath.ToSlash(c.path))
+		}
+	}
+	if len(aborted) > 0 {
+		sort.Strings(aborted)
+		return errors.Errorf(
+			"the destination '%s' already contains the paths:\n  %s\nChoose another conflict policy to continue",
+			dest,
+			strings.Join(aborted, "\n  "),
+		)
+	}
+
+	return nil
+}
+
+// promptConflict asks for the policy of the conflict until the user doesn't want to see the diff
+func promptConflict(src, dest string, c *fileConflict) (string, error) {
+	const showDiff = "show diff"
+
+	options := []string{ConflictOverwrite, ConflictSkip}
+	if !c.typeClash && !c.symlink {
+		options = append(options, ConflictMergeDiff, showDiff)
+	}
+	options = append(options, ConflictAbort)
+
+	for {
+		choice := ""
+		err := survey.AskOne(&survey.Select{
+			Message: fmt.Sprintf("'%s' already exists, what do you want to do?", filepath.ToSlash(c.path)),
+			Options: options,
+			Default: ConflictSkip,
+		}, &choice, nil)
+		if err != nil {
+			return "", err
+		}
+		if choice != showDiff {
+			return choice, nil
+		}
+
+		curDat, _ := readOptionalFile(filepath.Join(dest, c.path))
+		newDat, _ := readOptionalFile(filepath.Join(src, c.path))
+		if isBinary(curDat) || isBinary(newDat) {
+			fmt.Println("Binary files differ")
+			continue
+		}
+		rel := filepath.ToSlash(c.path)
+		fmt.Print(unifiedDiff(string(curDat), string(newDat), "project/"+rel, "template/"+rel))
+	}
+}
+
+// findConflict returns the conflict of the path or of one of its parent directories
+func findConflict(conflicts []*fileConflict, rel string) *fileConflict {
+	for _, c := range conflicts {
+		if c.path == rel || strings.HasPrefix(rel, c.path+string(filepath.Separator)) {
+			return c
+		}
+	}
+	return nil
+}
+
+// copyProject copies the files of src to dest and applies the policies of the conflicts.
+// The written paths are recorded in the journal of the checkout when it's not nil.
+// Empty directories, file modes and symlinks are kept.
+func copyProject(src, dest string, conflicts []*fileConflict, journal *checkout, opts ...utils.CopyOption) error {
+	policies := map[string]string{}
+	for _, c := range conflicts {
+		policies[c.path] = c.policy
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "walk failed")
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		switch policies[rel] {
+		case ConflictSkip:
+			logy.Debugf("skip existing '%s'", rel)
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		case ConflictOverwrite:
+			err = journal.record(rel, true)
+			if err != nil {
+				return err
+			}
+			err = os.RemoveAll(target)
+			if err != nil {
+				return errors.Wrapf(err, "overwrite '%s'", rel)
+			}
+		case ConflictMergeDiff:
+			err = journal.record(rel, true)
+			if err != nil {
+				return err
+			}
+			return mergeDiffFile(path, target, rel)
+		default:
+			err = journal.record(rel, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			// an equal symlink isn't a conflict
+			if sameSymlink(path, target) {
+				return nil
+			}
+			return utils.CopySymlink(path, target)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		return utils.CopyFile(path, target, opts...)
+	})
+}
+
+// sameSymlink reports whether both paths are symlinks with the same target
+func sameSymlink(a, b string) bool {
+	linkA, err := os.Readlink(a)
+	if err != nil {
+		return false
+	}
+	linkB, err := os.Readlink(b)
+	return err == nil && linkA == linkB
+}
+
+// mergeDiffFile merges the lines of the template file into the existing file
+func mergeDiffFile(src, target, rel string) error {
+	newDat, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	curDat, err := ioutil.ReadFile(target)
+	if err != nil {
+		return err
+	}
+
+	if isBinary(newDat) || isBinary(curDat) {
+		logy.Warnf("binary file '%s' can't be merged, the existing file is kept", rel)
+		return nil
+	}
+
+	merged, conflict := merge2(string(curDat), string(newDat), "project", "template")
+	if conflict {
+		logy.Warnf("'%s' contains conflict markers", rel)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(target, []byte(merged), info.Mode())
+	if err != nil {
+		return errors.Wrapf(err, "write '%s'", rel)
+	}
+
+	return nil
+}
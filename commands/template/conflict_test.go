@@ -0,0 +1,104 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/netzkern/butler/utils"
+)
+
+func TestCopyProject(t *testing.T) {
+	for _, preserveTimes := range []bool{false, true} {
+		dir := testTempDir(t)
+		defer os.RemoveAll(dir)
+
+		src := filepath.Join(dir, "src")
+		writeTestFile(t, filepath.Join(src, "gradlew"), "#!/bin/sh", 0755)
+		writeTestFile(t, filepath.Join(src, "git_hooks", "pre-commit"), "#!/bin/sh", 0755)
+		writeTestFile(t, filepath.Join(src, "docs", "readme.md"), "docs", 0644)
+		err := os.MkdirAll(filepath.Join(src, "empty"), 0755)
+		if err == nil {
+			err = os.Symlink("docs/readme.md", filepath.Join(src, "readme.md"))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// an equal symlink in the destination isn't replaced
+		dest := filepath.Join(dir, "dest")
+		err = os.MkdirAll(dest, 0755)
+		if err == nil {
+			err = os.Symlink("docs/readme.md", filepath.Join(dest, "readme.md"))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = copyProject(src, dest, nil, nil, utils.WithPreserveTimes(preserveTimes))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for path, mode := range map[string]os.FileMode{
+			"gradlew":              0755,
+			"git_hooks/pre-commit": 0755,
+			"docs/readme.md":       0644,
+		} {
+			info, err := os.Stat(filepath.Join(dest, filepath.FromSlash(path)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != mode {
+				t.Errorf("expected mode %v of '%s', got %v", mode, path, info.Mode().Perm())
+			}
+			if info.ModTime().Equal(oldTime) != preserveTimes {
+				t.Errorf("expected preserved mtime %v of '%s', got %v", preserveTimes, path, info.ModTime())
+			}
+		}
+
+		if info, err := os.Stat(filepath.Join(dest, "empty")); err != nil || !info.IsDir() {
+			t.Errorf("expected the empty directory to be kept: %v", err)
+		}
+		if link, _ := os.Readlink(filepath.Join(dest, "readme.md")); link != "docs/readme.md" {
+			t.Errorf("expected the symlink to be kept, got '%s'", link)
+		}
+	}
+}
+
+func TestCheckSymlinks(t *testing.T) {
+	tests := []struct {
+		name  string
+		link  string
+		valid bool
+	}{
+		{"relative", "docs/readme.md", true},
+		{"dangling inside", "missing", true},
+		{"absolute", "/etc/passwd", false},
+		{"parent", "../outside", false},
+		{"dangling parent", "../../missing", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testTempDir(t)
+			defer os.RemoveAll(dir)
+
+			writeTestFile(t, filepath.Join(dir, "outside"), "", 0644)
+			root := filepath.Join(dir, "template")
+			writeTestFile(t, filepath.Join(root, "docs", "readme.md"), "", 0644)
+			err := os.Symlink(tt.link, filepath.Join(root, "link"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = checkSymlinks(root)
+			if tt.valid && err != nil {
+				t.Errorf("expected a valid symlink, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Errorf("expected the symlink to '%s' to be rejected", tt.link)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package template
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	logy "github.com/apex/log"
+	"github.com/pkg/errors"
+)
+
+// resolveQuestion evaluates the templates of the default and the options and
+// loads the options of the source. It's called right before the question is asked
+// so that the templates have access to the answers given so far. The options of
+// skipped questions aren't loaded so that their commands aren't run.
+func (t *Templating) resolveQuestion(question Question, answers map[string]interface{}, asked bool) (Question, error) {
+	funcMap := t.answerFuncMap(answers)
+	render := func(text string) (string, error) {
+		if !isTemplateText(text, funcMap) {
+			return text, nil
+		}
+		return parseStringAsTemplate(t.TemplateData, funcMap, question.Name, text)
+	}
+
+	defaultValue, err := resolveDefault(question.Default, render)
+	if err != nil {
+		return question, errors.Wrapf(err, "default of question '%s'", question.Name)
+	}
+	// conditions are evaluated to strings
+	if s, ok := defaultValue.(string); ok && question.Type == "confirm" {
+		defaultValue, err = strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return question, errors.Errorf("default of question '%s' must be a boolean but got '%s'", question.Name, s)
+		}
+	}
+	question.Default = defaultValue
+
+	options := make([]string, 0, len(question.Options))
+	for _, option := range question.Options {
+		option, err = render(option)
+		if err != nil {
+			return question, errors.Wrapf(err, "options of question '%s'", question.Name)
+		}
+		options = append(options, option)
+	}
+
+	if question.OptionsFrom != nil && asked {
+		loaded, err := t.loadOptions(*question.OptionsFrom, render, answers)
+		if err != nil {
+			return question, errors.Wrapf(err, "options of question '%s'", question.Name)
+		}
+		options = append(options, loaded...)
+		if len(options) == 0 {
+			return question, errors.Errorf("question '%s' has no options", question.Name)
+		}
+		// the options of the source are known now
+		question.OptionsFrom = nil
+	}
+	question.Options = options
+
+	// the question getters return the resolved question
+	for i := range t.templateConfig.Questions {
+		if t.templateConfig.Questions[i].Name == question.Name {
+			t.templateConfig.Questions[i] = question
+		}
+	}
+
+	return question, nil
+}
+
+// isTemplateText reports whether the text is evaluated as template. Texts without
+// actions or which don't parse e.g json are used literally so that static values
+// don't have to escape braces.
+func isTemplateText(text string, funcMap template.FuncMap) bool {
+	if !strings.Contains(text, startNameDelim) {
+		return false
+	}
+	tpl, err := template.New("").Delims(startNameDelim, endNameDelim).Funcs(funcMap).Parse(text)
+	if err != nil {
+		return false
+	}
+	for _, node := range tpl.Tree.Root.Nodes {
+		if node.Type() != parse.NodeText {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDefault evaluates the templates of a default value. The entries of lists
+// and the values of maps are evaluated, other values are kept as they are.
+func resolveDefault(value interface{}, render func(string) (string, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return render(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			r, err := resolveDefault(e, render)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = r
+		}
+		return list, nil
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for k, e := range v {
+			r, err := resolveDefault(e, render)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = r
+		}
+		return m, nil
+	}
+
+	return value, nil
+}
+
+// loadOptions runs the command or reads the file of the source in the template
+// directory. Every non-empty line is an option.
+func (t *Templating) loadOptions(src OptionsSource, render func(string) (string, error), answers map[string]interface{}) ([]string, error) {
+	if src.File != "" {
+		file, err := render(src.File)
+		if err != nil {
+			return nil, err
+		}
+		dat, err := ioutil.ReadFile(filepath.Join(t.templateDir, filepath.Clean("/"+file)))
+		if err != nil {
+			return nil, errors.Wrap(err, "read options file")
+		}
+		return splitOptions(dat), nil
+	}
+
+	args := make([]string, 0, len(src.Args))
+	for _, arg := range src.Args {
+		arg, err := render(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	logy.Debugf("load options from command '%s' with args %v", src.Cmd, args)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(src.Cmd, args...)
+	// the previous answers are exposed like in the hooks
+	cmd.Env = append(mapToEnvArray(answers, envPrefix), os.Environ()...)
+	cmd.Dir = t.templateDir
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "command '%s' failed: %s", src.Cmd, strings.TrimSpace(stderr.String()))
+	}
+
+	return splitOptions(out), nil
+}
+
+// splitOptions returns the trimmed non-empty lines
+func splitOptions(dat []byte) []string {
+	options := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(dat))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" {
+			options = append(options, line)
+		}
+	}
+	return options
+}
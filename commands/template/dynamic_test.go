@@ -0,0 +1,27 @@
+package template
+
+import (
+	"testing"
+	"text/template"
+)
+
+func TestIsTemplateText(t *testing.T) {
+	funcs := template.FuncMap{"getName": func() string { return "" }}
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"plain", false},
+		{`{"port": 8080}`, false},
+		{"{ unknown }", false},
+		{"{ getName }", true},
+		{"{ .Project.Name }-api", true},
+		{`{"{"}`, true},
+	}
+
+	for _, tt := range tests {
+		if got := isTemplateText(tt.text, funcs); got != tt.want {
+			t.Errorf("isTemplateText(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// templateErrPattern matches the position and the expression of parse and execution
+// errors of text/template e.g `template: name:3:12: executing "name" at <getFoo>: msg`
+var templateErrPattern = regexp.MustCompile(`(?s)^template: .*?:(\d+)(?::(\d+))?: (?:executing ".*?" at <(.*?)>: )?(.*)$`)
+
+type (
+	// TemplateError is an error of a file of the template which could not be rendered
+	TemplateError struct {
+		Path       string `json:"path"`
+		Line       int    `json:"line,omitempty"`
+		Column     int    `json:"column,omitempty"`
+		Expression string `json:"expression,omitempty"`
+		Message    string `json:"message"`
+	}
+	// TemplateErrorReport contains all errors of a run
+	TemplateErrorReport struct {
+		Template string           `json:"template"`
+		Errors   []*TemplateError `json:"errors"`
+	}
+)
+
+// newTemplateError extracts the position and the expression of the error. The path is
+// relative to the template root, the context describes which part of the file failed.
+func newTemplateError(root, path string, err error, context string) *TemplateError {
+	rel, relErr := filepath.Rel(root, path)
+	if relErr != nil {
+		rel = path
+	}
+
+	// the names of the templates e.g of includes contain the temporary directory
+	message := strings.Replace(errors.Cause(err).Error(), root+string(filepath.Separator), "", -1)
+	e := &TemplateError{Path: filepath.ToSlash(rel), Message: message}
+	if m := templateErrPattern.FindStringSubmatch(e.Message); m != nil {
+		e.Line, _ = strconv.Atoi(m[1])
+		e.Column, _ = strconv.Atoi(m[2])
+		e.Expression = m[3]
+		e.Message = m[4]
+	}
+	if context != "" {
+		e.Message = context + ": " + e.Message
+	}
+
+	return e
+}
+
+// Error returns the error as path:line:column: message
+func (e *TemplateError) Error() string {
+	pos := e.Path
+	if e.Line > 0 {
+		pos += ":" + strconv.Itoa(e.Line)
+	}
+	if e.Column > 0 {
+		pos += ":" + strconv.Itoa(e.Column)
+	}
+	if e.Expression != "" {
+		return fmt.Sprintf("%s: at <%s>: %s", pos, e.Expression, e.Message)
+	}
+	return pos + ": " + e.Message
+}
+
+// sortTemplateErrors sorts the errors by their position because the workers report
+// them in any order
+func sortTemplateErrors(errs []*TemplateError) {
+	sort.Slice(errs, func(i, j int) bool {
+		a, b := errs[i], errs[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// printTemplateErrors prints the errors of the run
+func printTemplateErrors(output io.Writer, errs []*TemplateError) {
+	if len(errs) == 0 {
+		return
+	}
+	fmt.Fprintf(output, "Template errors (%d):\n", len(errs))
+	for _, e := range errs {
+		fmt.Fprintf(output, "  %s\n", e)
+	}
+}
+
+// writeErrorReport writes the errors of the run as json to the report file
+func (t *Templating) writeErrorReport(name string) error {
+	if t.errorReport == "" {
+		return nil
+	}
+
+	report := &TemplateErrorReport{Template: name, Errors: t.templateErrors}
+	if report.Errors == nil {
+		report.Errors = []*TemplateError{}
+	}
+	dat, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "error report could not be marshaled")
+	}
+
+	err = ioutil.WriteFile(t.errorReport, append(dat, '\n'), 0644)
+	if err != nil {
+		return errors.Wrap(err, "write error report")
+	}
+
+	return nil
+}
@@ -0,0 +1,621 @@
+package template
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pinzolo/casee"
+	uuid "github.com/satori/go.uuid"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// newFuncMap returns the helper functions which are available in file contents,
+// file names, variables and expressions of the survey. The names follow Sprig
+// where possible.
+func (t *Templating) newFuncMap() template.FuncMap {
+	funcs := template.FuncMap{
+		// string helper funcs
+		"toCamelCase":  casee.ToCamelCase,
+		"toPascalCase": casee.ToPascalCase,
+		"toSnakeCase":  casee.ToSnakeCase,
+		"toKebabCase":  casee.ToChainCase,
+		"toTitleCase":  toTitleCase,
+		"toLowerCase":  strings.ToLower,
+		"toUpperCase":  strings.ToUpper,
+		"join":         strings.Join,
+		"replace":      strings.Replace,
+		"contains":     strings.Contains,
+		"index":        strings.Index,
+		"repeat":       strings.Repeat,
+		"split":        strings.Split,
+		"indent":       indent,
+		"kebabcase":    casee.ToChainCase,
+		"snakecase":    casee.ToSnakeCase,
+		"title":        strings.Title,
+		"lower":        strings.ToLower,
+		"upper":        strings.ToUpper,
+		"trim":         strings.TrimSpace,
+		"trimAll":      func(cutset, s string) string { return strings.Trim(s, cutset) },
+		"trimPrefix":   func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix":   func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"hasPrefix":    func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":    func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"quote":        func(s string) string { return strconv.Quote(s) },
+		"squote":       func(s string) string { return "'" + s + "'" },
+		"trunc":        trunc,
+		"pluralize":    pluralize,
+		"singularize":  singularize,
+		// defaults
+		"default":  defaultValue,
+		"empty":    empty,
+		"coalesce": coalesce,
+		"ternary":  ternary,
+		// collections
+		"list":      func(v ...interface{}) []interface{} { return v },
+		"dict":      dict,
+		"has":       has,
+		"hasKey":    hasKey,
+		"keys":      keys,
+		"first":     first,
+		"last":      last,
+		"uniq":      uniq,
+		"sortAlpha": sortAlpha,
+		// encoding
+		"sha256sum":    func(s string) string { sum := sha256.Sum256([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"sha1sum":      func(s string) string { sum := sha1.Sum([]byte(s)); return hex.EncodeToString(sum[:]) },
+		"b64enc":       func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) },
+		"b64dec":       b64dec,
+		"toJson":       toJSON,
+		"toPrettyJson": toPrettyJSON,
+		"fromJson":     fromJSON,
+		"toYaml":       toYAML,
+		"fromYaml":     fromYAML,
+		// dates
+		"now":        t.now,
+		"date":       formatDate,
+		"dateInZone": formatDateInZone,
+		"dateModify": dateModify,
+		"toDate":     toDate,
+		"unixEpoch":  func(date time.Time) string { return strconv.FormatInt(date.Unix(), 10) },
+		// path
+		"joinPath": filepath.Join,
+		"relPath":  filepath.Rel,
+		"basePath": filepath.Base,
+		"extPath":  filepath.Ext,
+		"absPath":  filepath.Abs,
+		// regexp
+		"regex": func(str string) *regexp.Regexp {
+			return regexp.MustCompile(str)
+		},
+		//environment
+		"cwd": func() string { return t.CommandData.Path },
+		"env": func(name string) string { return os.Getenv(name) },
+	}
+
+	for name, fn := range t.randomFuncs("") {
+		funcs[name] = fn
+	}
+
+	return funcs
+}
+
+// randomFuncs returns the generators. Their source is derived from the seed and the
+// key so the values of a file don't depend on the order in which the files are rendered.
+func (t *Templating) randomFuncs(key string) template.FuncMap {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	random := &lockedRand{r: rand.New(rand.NewSource(t.seed ^ int64(h.Sum64())))}
+
+	return template.FuncMap{
+		"uuid":         random.uuid,
+		"randomInt":    random.intRange,
+		"randAlphaNum": func(n int) string { return random.chars(n, alphaNum) },
+		"randNumeric":  func(n int) string { return random.chars(n, numeric) },
+	}
+}
+
+// keyedFuncMap returns the helper functions with generators for the key e.g the
+// path of a file in the template
+func (t *Templating) keyedFuncMap(key string) template.FuncMap {
+	funcs := template.FuncMap{}
+	for name, fn := range t.templateFuncMap {
+		funcs[name] = fn
+	}
+	for name, fn := range t.randomFuncs(key) {
+		funcs[name] = fn
+	}
+	return funcs
+}
+
+const (
+	alphaNum = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	numeric  = "0123456789"
+)
+
+// lockedRand is a random source which can be used by the workers at the same time
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+// chars returns n random characters of the alphabet
+func (l *lockedRand) chars(n int, alphabet string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[l.r.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+// randomKey returns the path of the file relative to the template which identifies
+// the generators of the file
+func (t *Templating) randomKey(path string) string {
+	rel, err := filepath.Rel(t.templateDir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// uuid returns a random UUID version 4
+func (l *lockedRand) uuid() uuid.UUID {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	u := uuid.UUID{}
+	l.r.Read(u[:])
+	u.SetVersion(uuid.V4)
+	u.SetVariant(uuid.VariantRFC4122)
+	return u
+}
+
+// intRange returns a random int between min and max, both are included
+func (l *lockedRand) intRange(min, max int) (int, error) {
+	if min > max {
+		return 0, fmt.Errorf("min %d is greater than max %d", min, max)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return min + l.r.Intn(max-min+1), nil
+}
+
+// toTitleCase capitalizes the words of the text e.g "my-project" is "My Project"
+func toTitleCase(s string) string {
+	return strings.Title(strings.Replace(casee.ToSnakeCase(s), "_", " ", -1))
+}
+
+// trunc shortens the text to the length, a negative length keeps the end of the text
+func trunc(length int, s string) string {
+	switch {
+	case length < 0 && len(s)+length > 0:
+		return s[len(s)+length:]
+	case length >= 0 && len(s) > length:
+		return s[:length]
+	}
+	return s
+}
+
+var (
+	// irregular words and words which can't be derived from the plural
+	irregularPlurals = map[string]string{
+		"alias":     "aliases",
+		"axis":      "axes",
+		"bus":       "buses",
+		"cache":     "caches",
+		"child":     "children",
+		"cookie":    "cookies",
+		"crisis":    "crises",
+		"criterion": "criteria",
+		"diagnosis": "diagnoses",
+		"echo":      "echoes",
+		"foot":      "feet",
+		"goose":     "geese",
+		"half":      "halves",
+		"hero":      "heroes",
+		"knife":     "knives",
+		"leaf":      "leaves",
+		"life":      "lives",
+		"man":       "men",
+		"mouse":     "mice",
+		"movie":     "movies",
+		"ox":        "oxen",
+		"person":    "people",
+		"potato":    "potatoes",
+		"quiz":      "quizzes",
+		"shelf":     "shelves",
+		"status":    "statuses",
+		"tomato":    "tomatoes",
+		"tooth":     "teeth",
+		"veto":      "vetoes",
+		"virus":     "viruses",
+		"wife":      "wives",
+		"wolf":      "wolves",
+		"woman":     "women",
+	}
+	uncountables = map[string]struct{}{
+		"data":        {},
+		"equipment":   {},
+		"information": {},
+		"metadata":    {},
+		"news":        {},
+		"series":      {},
+		"sheep":       {},
+		"species":     {},
+	}
+)
+
+// pluralize returns the english plural of the word
+func pluralize(word string) string {
+	lower := strings.ToLower(word)
+	if _, ok := uncountables[lower]; ok || word == "" {
+		return word
+	}
+	if plural, ok := irregularPlurals[lower]; ok {
+		return matchCase(word, plural)
+	}
+
+	switch {
+	// analysis, thesis
+	case strings.HasSuffix(lower, "sis"):
+		return word[:len(word)-2] + "es"
+	// a single z after a vowel is doubled e.g fez
+	case strings.HasSuffix(lower, "z") && len(lower) > 1 && isVowel(lower[len(lower)-2]):
+		return word + "zes"
+	case hasAnySuffix(lower, "s", "x", "z", "ch", "sh"):
+		return word + "es"
+	case strings.HasSuffix(lower, "y") && !hasAnySuffix(lower, "ay", "ey", "iy", "oy", "uy"):
+		return word[:len(word)-1] + "ies"
+	}
+	return word + "s"
+}
+
+// singularize returns the english singular of the word
+func singularize(word string) string {
+	lower := strings.ToLower(word)
+	if _, ok := uncountables[lower]; ok || word == "" {
+		return word
+	}
+	// the word is already singular
+	if _, ok := irregularPlurals[lower]; ok {
+		return word
+	}
+	for singular, plural := range irregularPlurals {
+		if lower == plural {
+			return matchCase(word, singular)
+		}
+	}
+
+	switch {
+	case hasAnySuffix(lower, "sis", "ss"):
+		return word
+	// analyses, hypotheses
+	case hasAnySuffix(lower, "yses", "theses"):
+		return word[:len(word)-2] + "is"
+	case strings.HasSuffix(lower, "ies") && len(word) > 3:
+		return word[:len(word)-3] + "y"
+	case hasAnySuffix(lower, "sses", "xes", "ches", "shes"):
+		return word[:len(word)-2]
+	// buzzes, waltzes but not sizes
+	case strings.HasSuffix(lower, "zes") && len(lower) > 3 && !isVowel(lower[len(lower)-4]):
+		return word[:len(word)-2]
+	case strings.HasSuffix(lower, "s"):
+		return word[:len(word)-1]
+	}
+	return word
+}
+
+// isVowel reports whether the letter is a vowel
+func isVowel(c byte) bool {
+	return strings.IndexByte("aeiou", c) != -1
+}
+
+// matchCase returns the replacement with an upper first letter when the word has one
+func matchCase(word, replacement string) string {
+	if word != "" && strings.ToUpper(word[:1]) == word[:1] {
+		return strings.ToUpper(replacement[:1]) + replacement[1:]
+	}
+	return replacement
+}
+
+func hasAnySuffix(s string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// empty reports whether the value is nil, false, zero or has no elements
+func empty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return reflect.DeepEqual(v, reflect.Zero(rv.Type()).Interface())
+}
+
+// defaultValue returns the value or the default when the value is empty
+func defaultValue(def interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || empty(v[0]) {
+		return def
+	}
+	return v[0]
+}
+
+// coalesce returns the first value which isn't empty
+func coalesce(v ...interface{}) interface{} {
+	for _, e := range v {
+		if !empty(e) {
+			return e
+		}
+	}
+	return nil
+}
+
+// ternary returns the first value when the condition is true otherwise the second
+func ternary(vt, vf interface{}, cond bool) interface{} {
+	if cond {
+		return vt
+	}
+	return vf
+}
+
+// dict creates a map from key value pairs
+func dict(v ...interface{}) (map[string]interface{}, error) {
+	if len(v)%2 != 0 {
+		return nil, fmt.Errorf("dict requires key value pairs")
+	}
+	d := make(map[string]interface{}, len(v)/2)
+	for i := 0; i < len(v); i += 2 {
+		d[fmt.Sprint(v[i])] = v[i+1]
+	}
+	return d, nil
+}
+
+// toList converts a slice or an array of any type
+func toList(list interface{}) ([]interface{}, error) {
+	rv := reflect.ValueOf(list)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		l := make([]interface{}, rv.Len())
+		for i := range l {
+			l[i] = rv.Index(i).Interface()
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("expected a list but got %T", list)
+}
+
+// has reports whether the list contains the value
+func has(needle interface{}, list interface{}) (bool, error) {
+	l, err := toList(list)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range l {
+		if reflect.DeepEqual(e, needle) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasKey reports whether the map has the key
+func hasKey(dict interface{}, key string) (bool, error) {
+	rv := reflect.ValueOf(dict)
+	if rv.Kind() != reflect.Map {
+		return false, fmt.Errorf("expected a map but got %T", dict)
+	}
+	for _, k := range rv.MapKeys() {
+		if fmt.Sprint(k.Interface()) == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// keys returns the sorted keys of the maps
+func keys(dicts ...interface{}) ([]string, error) {
+	k := []string{}
+	for _, d := range dicts {
+		rv := reflect.ValueOf(d)
+		if rv.Kind() != reflect.Map {
+			return nil, fmt.Errorf("expected a map but got %T", d)
+		}
+		for _, key := range rv.MapKeys() {
+			k = append(k, fmt.Sprint(key.Interface()))
+		}
+	}
+	sort.Strings(k)
+	return k, nil
+}
+
+func first(list interface{}) (interface{}, error) {
+	l, err := toList(list)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[0], nil
+}
+
+func last(list interface{}) (interface{}, error) {
+	l, err := toList(list)
+	if err != nil || len(l) == 0 {
+		return nil, err
+	}
+	return l[len(l)-1], nil
+}
+
+// uniq removes the duplicates of the list
+func uniq(list interface{}) ([]interface{}, error) {
+	l, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	result := []interface{}{}
+	for _, e := range l {
+		if ok, _ := has(e, result); !ok {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// sortAlpha sorts the list by the string representation of the values
+func sortAlpha(list interface{}) ([]string, error) {
+	l, err := toList(list)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(l))
+	for i, e := range l {
+		result[i] = fmt.Sprint(e)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func b64dec(s string) (string, error) {
+	dat, err := base64.StdEncoding.DecodeString(s)
+	return string(dat), err
+}
+
+func toJSON(v interface{}) (string, error) {
+	dat, err := json.Marshal(jsonValue(v))
+	return string(dat), err
+}
+
+func toPrettyJSON(v interface{}) (string, error) {
+	dat, err := json.MarshalIndent(jsonValue(v), "", "  ")
+	return string(dat), err
+}
+
+func fromJSON(s string) (interface{}, error) {
+	var v interface{}
+	err := json.Unmarshal([]byte(s), &v)
+	return v, err
+}
+
+func toYAML(v interface{}) (string, error) {
+	dat, err := yaml.Marshal(v)
+	return strings.TrimSuffix(string(dat), "\n"), err
+}
+
+func fromYAML(s string) (interface{}, error) {
+	var v interface{}
+	err := yaml.Unmarshal([]byte(s), &v)
+	return jsonValue(v), err
+}
+
+// jsonValue converts the maps of yaml documents which can't be encoded as json
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = jsonValue(e)
+		}
+		return l
+	}
+	return v
+}
+
+// toTime converts a time, a unix timestamp or a RFC3339 or "2006-01-02" string
+func toTime(date interface{}) (time.Time, error) {
+	switch d := date.(type) {
+	case time.Time:
+		return d, nil
+	case *time.Time:
+		return *d, nil
+	case int:
+		return time.Unix(int64(d), 0), nil
+	case int64:
+		return time.Unix(d, 0), nil
+	case string:
+		if len(d) == len("2006-01-02") {
+			return time.Parse("2006-01-02", d)
+		}
+		return time.Parse(time.RFC3339, d)
+	}
+	return time.Time{}, fmt.Errorf("expected a date but got %T", date)
+}
+
+// formatDate formats the date with the layout of the go time package e.g "2006-01-02"
+func formatDate(layout string, date interface{}) (string, error) {
+	d, err := toTime(date)
+	if err != nil {
+		return "", err
+	}
+	return d.Format(layout), nil
+}
+
+// formatDateInZone formats the date in the time zone e.g "Europe/Berlin"
+func formatDateInZone(layout string, date interface{}, zone string) (string, error) {
+	d, err := toTime(date)
+	if err != nil {
+		return "", err
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return "", err
+	}
+	return d.In(loc).Format(layout), nil
+}
+
+// dateModify adds the duration e.g "-1.5h" or "24h" to the date
+func dateModify(duration string, date interface{}) (time.Time, error) {
+	d, err := toTime(date)
+	if err != nil {
+		return d, err
+	}
+	dur, err := time.ParseDuration(duration)
+	if err != nil {
+		return d, err
+	}
+	return d.Add(dur), nil
+}
+
+// toDate parses the text with the layout
+func toDate(layout, s string) (time.Time, error) {
+	return time.Parse(layout, s)
+}
@@ -0,0 +1,236 @@
+package template
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestFuncMap(t *testing.T) {
+	os.Setenv("BUTLER_TEST_ENV", "value")
+	defer os.Unsetenv("BUTLER_TEST_ENV")
+
+	abs, err := filepath.Abs("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text string
+		want string
+		// pattern is matched instead of want for random values
+		pattern string
+	}{
+		// strings
+		{text: `{{ toCamelCase "my-project" }}`, want: "myProject"},
+		{text: `{{ toPascalCase "my-project" }}`, want: "MyProject"},
+		{text: `{{ toSnakeCase "myProject" }}`, want: "my_project"},
+		{text: `{{ toKebabCase "myProject" }}`, want: "my-project"},
+		{text: `{{ toTitleCase "myProject" }}`, want: "My Project"},
+		{text: `{{ toLowerCase "ABC" }}`, want: "abc"},
+		{text: `{{ toUpperCase "abc" }}`, want: "ABC"},
+		{text: `{{ join (split "a,b" ",") "-" }}`, want: "a-b"},
+		{text: `{{ replace "a.b.c" "." "/" -1 }}`, want: "a/b/c"},
+		{text: `{{ contains "abc" "b" }}`, want: "true"},
+		{text: `{{ index "abc" "c" }}`, want: "2"},
+		{text: `{{ repeat "ab" 2 }}`, want: "abab"},
+		{text: `{{ indent 2 "a\nb" }}`, want: "  a\n  b"},
+		{text: `{{ kebabcase "MyProject" }}`, want: "my-project"},
+		{text: `{{ snakecase "MyProject" }}`, want: "my_project"},
+		{text: `{{ title "my project" }}`, want: "My Project"},
+		{text: `{{ lower "ABC" }}`, want: "abc"},
+		{text: `{{ upper "abc" }}`, want: "ABC"},
+		{text: `{{ trim " a " }}`, want: "a"},
+		{text: `{{ trimAll "-" "--a--" }}`, want: "a"},
+		{text: `{{ trimPrefix "my-" "my-project" }}`, want: "project"},
+		{text: `{{ trimSuffix "-api" "project-api" }}`, want: "project"},
+		{text: `{{ hasPrefix "my" "my-project" }}`, want: "true"},
+		{text: `{{ hasSuffix "api" "my-project" }}`, want: "false"},
+		{text: `{{ quote "a\"b" }}`, want: `"a\"b"`},
+		{text: `{{ squote "a" }}`, want: "'a'"},
+		{text: `{{ trunc 2 "abc" }}`, want: "ab"},
+		{text: `{{ trunc -2 "abc" }}`, want: "bc"},
+		{text: `{{ trunc 5 "abc" }}`, want: "abc"},
+		{text: `{{ pluralize "category" }}`, want: "categories"},
+		{text: `{{ singularize "categories" }}`, want: "category"},
+		// defaults
+		{text: `{{ default "def" "" }}`, want: "def"},
+		{text: `{{ default "def" "value" }}`, want: "value"},
+		{text: `{{ empty 0 }} {{ empty list }} {{ empty "a" }}`, want: "true true false"},
+		{text: `{{ coalesce "" 0 "a" }}`, want: "a"},
+		{text: `{{ ternary "yes" "no" false }}`, want: "no"},
+		// collections
+		{text: `{{ list 1 "a" }}`, want: "[1 a]"},
+		{text: `{{ $d := dict "a" 1 "b" 2 }}{{ $d.b }}`, want: "2"},
+		{text: `{{ has "b" (list "a" "b") }}`, want: "true"},
+		{text: `{{ has "c" (split "a,b" ",") }}`, want: "false"},
+		{text: `{{ hasKey (dict "a" 1) "a" }} {{ hasKey (dict "a" 1) "b" }}`, want: "true false"},
+		{text: `{{ keys (dict "b" 1 "a" 2) (dict "c" 3) }}`, want: "[a b c]"},
+		{text: `{{ first (list 1 2) }} {{ last (list 1 2) }}`, want: "1 2"},
+		{text: `{{ first list }}`, want: "<no value>"},
+		{text: `{{ uniq (list 1 2 1) }}`, want: "[1 2]"},
+		{text: `{{ sortAlpha (list "b" 2 "a") }}`, want: "[2 a b]"},
+		// encoding
+		{text: `{{ sha256sum "a" }}`, want: "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"},
+		{text: `{{ sha1sum "a" }}`, want: "86f7e437faa5a7fce15d1ddcb9eaeaea377667b8"},
+		{text: `{{ b64enc "abc" }}`, want: "YWJj"},
+		{text: `{{ b64dec "YWJj" }}`, want: "abc"},
+		{text: `{{ toJson (dict "a" (list 1 "b")) }}`, want: `{"a":[1,"b"]}`},
+		{text: `{{ toPrettyJson (dict "a" 1) }}`, want: "{\n  \"a\": 1\n}"},
+		{text: `{{ $v := fromJson "{\"a\":[1]}" }}{{ $v.a }}`, want: "[1]"},
+		{text: `{{ toYaml (dict "a" 1) }}`, want: "a: 1"},
+		{text: `{{ toJson (fromYaml "a:\n  b: 1") }}`, want: `{"a":{"b":1}}`},
+		// dates
+		{text: `{{ date "2006-01-02T15:04" now }}`, want: "2018-01-02T03:04"},
+		{text: `{{ date "2006" "2019-05-06" }}`, want: "2019"},
+		{text: `{{ date "2006-01-02" 0 }}`, want: "1970-01-01"},
+		{text: `{{ dateInZone "15:04" now "Europe/Berlin" }}`, want: "04:04"},
+		{text: `{{ date "15:04" (dateModify "-1.5h" now) }}`, want: "01:34"},
+		{text: `{{ date "2006-01-02" (toDate "02.01.2006" "24.12.2019") }}`, want: "2019-12-24"},
+		{text: `{{ unixEpoch now }}`, want: "1514862245"},
+		// path
+		{text: `{{ joinPath "a" "b" }}`, want: filepath.Join("a", "b")},
+		{text: `{{ relPath "/a" "/a/b/c" }}`, want: filepath.Join("b", "c")},
+		{text: `{{ basePath "/a/b.go" }}`, want: "b.go"},
+		{text: `{{ extPath "/a/b.go" }}`, want: ".go"},
+		{text: `{{ absPath "file" }}`, want: abs},
+		// regexp
+		{text: `{{ (regex "^[a-z]+$").MatchString "abc" }}`, want: "true"},
+		// environment
+		{text: `{{ cwd }}`, want: "/projects"},
+		{text: `{{ env "BUTLER_TEST_ENV" }}`, want: "value"},
+		// random
+		{text: `{{ uuid }}`, pattern: `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{text: `{{ randomInt 5 7 }}`, pattern: `^[5-7]$`},
+		{text: `{{ randAlphaNum 12 }}`, pattern: `^[a-zA-Z0-9]{12}$`},
+		{text: `{{ randNumeric 6 }}`, pattern: `^[0-9]{6}$`},
+	}
+
+	funcs := newTestFuncMap()
+	used := map[string]bool{}
+	for _, tt := range tests {
+		got, err := executeTestTemplate(funcs, tt.text)
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		if tt.pattern != "" {
+			if !regexp.MustCompile(tt.pattern).MatchString(got) {
+				t.Errorf("%s: expected %q to match %s", tt.text, got, tt.pattern)
+			}
+		} else if got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.text, tt.want, got)
+		}
+		for _, name := range regexp.MustCompile(`[a-zA-Z0-9]+`).FindAllString(tt.text, -1) {
+			used[name] = true
+		}
+	}
+
+	for name := range funcs {
+		if !used[name] {
+			t.Errorf("function %s isn't tested", name)
+		}
+	}
+}
+
+func TestFuncMapErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{`{{ dict "a" }}`, "dict requires key value pairs"},
+		{`{{ has "a" "abc" }}`, "expected a list but got string"},
+		{`{{ hasKey (list 1) "a" }}`, "expected a map but got []interface {}"},
+		{`{{ keys (dict "a" 1) (list 1) }}`, "expected a map but got []interface {}"},
+		{`{{ first 1 }}`, "expected a list but got int"},
+		{`{{ uniq "a" }}`, "expected a list but got string"},
+		{`{{ sortAlpha 1 }}`, "expected a list but got int"},
+		{`{{ b64dec "%" }}`, "illegal base64 data"},
+		{`{{ fromJson "{" }}`, "unexpected end of JSON input"},
+		{`{{ toDate "2006-01-02" "24.12.2019" }}`, `cannot parse "24.12.2019"`},
+		{`{{ date "2006" "yesterday" }}`, `cannot parse "yesterday"`},
+		{`{{ date "2006" 1.5 }}`, "expected a date but got float64"},
+		{`{{ dateInZone "2006" now "Nowhere/Town" }}`, "unknown time zone Nowhere/Town"},
+		{`{{ dateModify "1 day" now }}`, `unknown unit`},
+		{`{{ dateModify "1h" "yesterday" }}`, `cannot parse "yesterday"`},
+		{`{{ randomInt 7 5 }}`, "min 7 is greater than max 5"},
+	}
+
+	funcs := newTestFuncMap()
+	for _, tt := range tests {
+		_, err := executeTestTemplate(funcs, tt.text)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.text, tt.err, err)
+		}
+	}
+}
+
+func TestInflection(t *testing.T) {
+	tests := []struct {
+		singular string
+		plural   string
+	}{
+		{"project", "projects"},
+		{"category", "categories"},
+		{"day", "days"},
+		{"class", "classes"},
+		{"box", "boxes"},
+		{"buzz", "buzzes"},
+		{"size", "sizes"},
+		{"waltz", "waltzes"},
+		{"quiz", "quizzes"},
+		{"match", "matches"},
+		{"dish", "dishes"},
+		{"hero", "heroes"},
+		{"potato", "potatoes"},
+		{"photo", "photos"},
+		{"repo", "repos"},
+		{"analysis", "analyses"},
+		{"hypothesis", "hypotheses"},
+		{"crisis", "crises"},
+		{"database", "databases"},
+		{"status", "statuses"},
+		{"alias", "aliases"},
+		{"leaf", "leaves"},
+		{"person", "people"},
+		{"child", "children"},
+		{"Mouse", "Mice"},
+		{"Quiz", "Quizzes"},
+		{"news", "news"},
+		{"data", "data"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := pluralize(tt.singular); got != tt.plural {
+			t.Errorf("pluralize(%q) = %q, want %q", tt.singular, got, tt.plural)
+		}
+		if got := singularize(tt.plural); got != tt.singular {
+			t.Errorf("singularize(%q) = %q, want %q", tt.plural, got, tt.singular)
+		}
+		if got := singularize(tt.singular); got != tt.singular {
+			t.Errorf("singularize(%q) = %q, want the word unchanged", tt.singular, got)
+		}
+	}
+}
+
+// newTestFuncMap returns the helper functions with a fixed seed and clock
+func newTestFuncMap() template.FuncMap {
+	command := New(WithSeed(1), WithNow(oldTime))
+	command.CommandData = &CommandData{Path: "/projects"}
+	return command.templateFuncMap
+}
+
+func executeTestTemplate(funcs template.FuncMap, text string) (string, error) {
+	tmpl, err := template.New("test").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, nil)
+	return buf.String(), err
+}
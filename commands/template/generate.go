@@ -0,0 +1,333 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	logy "github.com/apex/log"
+	"github.com/netzkern/butler/utils"
+	"github.com/pinzolo/casee"
+	"github.com/pkg/errors"
+)
+
+// GeneratorsDir is the default directory of the generator files in the template
+const GeneratorsDir = "_generators"
+
+// GenerateSummary contains all paths which were touched by a generator
+type GenerateSummary struct {
+	Generator   string
+	Added       []string
+	Overwritten []string
+	Merged      []string
+	Skipped     []string
+	Unchanged   []string
+}
+
+// PrintSummary print the summary on stdout
+func (s *GenerateSummary) PrintSummary(output io.Writer) {
+	fmt.Fprintf(output, "Generated '%s'\n", s.Generator)
+
+	groups := []struct {
+		name  string
+		paths []string
+	}{
+		{"Added", s.Added},
+		{"Overwritten", s.Overwritten},
+		{"Merged", s.Merged},
+		{"Skipped", s.Skipped},
+		{"Unchanged", s.Unchanged},
+	}
+
+	for _, g := range groups {
+		if len(g.paths) == 0 {
+			continue
+		}
+		sort.Strings(g.paths)
+		fmt.Fprintf(output, "%s (%d):\n", g.name, len(g.paths))
+		for _, p := range g.paths {
+			fmt.Fprintf(output, "  %s\n", p)
+		}
+	}
+}
+
+// generatorPath returns the directory of the generator files in the template
+func generatorPath(g Generator) string {
+	if g.Path != "" {
+		return g.Path
+	}
+	return filepath.Join(GeneratorsDir, g.Name)
+}
+
+// removeGenerators removes the files of all generators from the template
+func (t *Templating) removeGenerators(s *Survey, root string) error {
+	paths := []string{GeneratorsDir}
+	for _, g := range s.Generators {
+		paths = append(paths, generatorPath(g))
+	}
+
+	for _, p := range paths {
+		if err := validateTemplatePath(p); err != nil {
+			return errors.Wrap(err, "invalid generator path")
+		}
+		err := os.RemoveAll(filepath.Join(root, p))
+		if err != nil {
+			return errors.Wrapf(err, "remove generator files '%s'", p)
+		}
+	}
+
+	return nil
+}
+
+// selectGenerator replaces the files of the template with the files of the generator
+// and returns a survey with the questions and hooks of the generator. The partials of
+// the template are kept.
+func (t *Templating) selectGenerator(s *Survey, root string) (*Survey, error) {
+	var generator *Generator
+	names := []string{}
+	for i := range s.Generators {
+		names = append(names, s.Generators[i].Name)
+		if s.Generators[i].Name == t.generator {
+			generator = &s.Generators[i]
+		}
+	}
+	if generator == nil {
+		if len(names) == 0 {
+			return nil, errors.New("template has no generators")
+		}
+		return nil, errors.Errorf("generator '%s' could not be found, available generators: %s", t.generator, strings.Join(names, ", "))
+	}
+
+	genPath := generatorPath(*generator)
+	if err := validateTemplatePath(genPath); err != nil {
+		return nil, errors.Wrap(err, "invalid generator path")
+	}
+
+	partials := s.Partials
+	if partials == "" {
+		partials = DefaultPartialsDir
+	}
+	if err := validateTemplatePath(partials); err != nil {
+		return nil, errors.Wrap(err, "invalid partials directory")
+	}
+
+	staging, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temp folder failed")
+	}
+	defer os.RemoveAll(staging)
+
+	// a generator without files only runs its hooks
+	if utils.Exists(filepath.Join(root, genPath)) {
+		err = utils.MoveDir(filepath.Join(root, genPath), staging)
+		if err != nil {
+			return nil, errors.Wrapf(err, "generator files '%s' could not be copied", genPath)
+		}
+	}
+	if utils.Exists(filepath.Join(root, partials)) {
+		err = utils.CopyDir(filepath.Join(root, partials), filepath.Join(staging, partials))
+		if err != nil {
+			return nil, errors.Wrap(err, "partials could not be copied")
+		}
+	}
+
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, errors.Wrap(err, "read template")
+	}
+	for _, entry := range entries {
+		err = os.RemoveAll(filepath.Join(root, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "remove template files")
+		}
+	}
+	err = utils.MoveDir(staging, root)
+	if err != nil {
+		return nil, errors.Wrap(err, "generator files could not be copied")
+	}
+
+	variables := map[string]interface{}{}
+	for k, v := range s.Variables {
+		variables[k] = v
+	}
+	for k, v := range generator.Variables {
+		variables[k] = v
+	}
+
+	logy.Debugf("use generator '%s' from %s", generator.Name, genPath)
+
+	return &Survey{
+		Questions:        generator.Questions,
+		AfterHooks:       generator.AfterHooks,
+		Variables:        variables,
+		ButlerVersion:    s.ButlerVersion,
+		Exclude:          generator.Exclude,
+		CopyOnly:         generator.CopyOnly,
+		Include:          generator.Include,
+		Partials:         s.Partials,
+		Conflicts:        s.Conflicts,
+		BinaryExtensions: s.BinaryExtensions,
+		TextExtensions:   s.TextExtensions,
+	}, nil
+}
+
+// Generate renders the generator of the project template and adds the files to the
+// project in the path of the command data. The template is rendered at the commit of
+// the manifest. The answers of the project are accessible with their getters. Files
+// which already exist with a different content are handled by the conflict policies.
+func (t *Templating) Generate(name string) (summary *GenerateSummary, err error) {
+	projectDir, err := filepath.Abs(t.CommandData.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "project abs failed")
+	}
+
+	manifest, err := ReadManifest(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	tpl := t.manifestTemplate(manifest)
+	if archiveFormat(tpl.URL) == "" {
+		t.revision = manifest.Template.Commit
+	}
+
+	t.generator = name
+	cd := manifest.Project
+	cd.Path = projectDir
+	t.CommandData = &cd
+
+	// the answers of the project are available in the generator
+	for k, v := range manifest.Answers {
+		t.templateFuncMap["get"+casee.ToPascalCase(k)] = answerGetter(v)
+	}
+
+	tempDir, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temp folder failed")
+	}
+	defer t.cleanTemplate(tempDir)
+
+	if t.dryRun {
+		t.preview = NewPreview(tempDir)
+	}
+
+	errCount, err := t.render(tpl, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	printTemplateErrors(os.Stdout, t.templateErrors)
+	if err = t.writeErrorReport(tpl.Name); err != nil {
+		return nil, err
+	}
+	if err = t.removeButlerFiles(tempDir); err != nil {
+		return nil, err
+	}
+	if errCount > 0 {
+		return nil, errors.Errorf("generator '%s' contains %d errors", name, errCount)
+	}
+
+	conflicts, err := t.detectConflicts(tempDir, projectDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "detect conflicts")
+	}
+
+	if t.dryRun {
+		err = t.preview.Print(os.Stdout, fmt.Sprintf("Preview of generator '%s' in '%s':", name, projectDir))
+		if err != nil {
+			return nil, errors.Wrap(err, "print preview")
+		}
+		printConflicts(os.Stdout, projectDir, conflicts)
+		return &GenerateSummary{Generator: name}, nil
+	}
+
+	printConflicts(os.Stdout, projectDir, conflicts)
+	err = t.resolveConflicts(tempDir, projectDir, conflicts)
+	if err != nil {
+		return nil, err
+	}
+
+	summary = &GenerateSummary{Generator: name}
+	files, err := listFiles(tempDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range files {
+		c := findConflict(conflicts, rel)
+		switch {
+		case c != nil && c.policy == ConflictOverwrite:
+			summary.Overwritten = append(summary.Overwritten, rel)
+		case c != nil && c.policy == ConflictMergeDiff:
+			summary.Merged = append(summary.Merged, rel)
+		case c != nil:
+			summary.Skipped = append(summary.Skipped, rel)
+		case utils.Exists(filepath.Join(projectDir, rel)):
+			summary.Unchanged = append(summary.Unchanged, rel)
+		default:
+			summary.Added = append(summary.Added, rel)
+		}
+	}
+
+	confirmed, err := t.confirmPackTemplate(fmt.Sprintf(
+		"Do you really want to add the files of generator '%s' to '%s' ?",
+		name,
+		projectDir,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, errManualTermination
+	}
+
+	co, err := newCheckout(projectDir, t.copyOptions()...)
+	if err != nil {
+		return nil, errors.Wrap(err, "inspect project failed")
+	}
+	err = co.write(tempDir, conflicts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := co.clean(); err != nil {
+			logy.WithError(err).Error("clean checkout failed")
+		}
+	}()
+
+	t.TaskTracker.Track("After hooks")
+	err = t.runSurveyTemplateHooks(projectDir)
+	if err != nil {
+		logy.WithError(err).Error("generator hooks failed")
+		t.rollbackCheckout(co)
+		return nil, err
+	}
+	t.TaskTracker.UnTrack("After hooks")
+
+	// passwords are never recorded
+	answers := map[string]interface{}{}
+	for k, v := range t.surveyResult {
+		answers[k] = v
+	}
+	for _, question := range t.templateConfig.Questions {
+		if question.Type == "password" {
+			delete(answers, question.Name)
+		}
+	}
+	manifest.Generators = append(manifest.Generators, ManifestGenerator{
+		Name:      name,
+		Answers:   answers,
+		CreatedAt: time.Now(),
+		Render:    t.manifestRender(),
+	})
+
+	err = WriteManifest(projectDir, manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,153 @@
+package template
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	logy "github.com/apex/log"
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+	git "gopkg.in/src-d/go-git.v4"
+	gitconfig "gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+var commitHashPattern = regexp.MustCompile("^[0-9a-f]{7,40}$")
+
+// gitRef is the resolved ref of a template
+type gitRef struct {
+	// reference to clone a single branch or tag
+	name plumbing.ReferenceName
+	// commit to checkout after a full clone
+	commit string
+}
+
+// resolveGitRef resolves a branch, tag, semver constraint or commit hash against the
+// references of the remote repository. An empty ref resolves to the default branch.
+func resolveGitRef(url, ref string, options *git.ListOptions) (*gitRef, error) {
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{url},
+	})
+
+	refs, err := remote.List(options)
+	if err != nil {
+		return nil, errors.Wrap(gitError(url, err), "list remote references")
+	}
+
+	if ref == "" {
+		for _, r := range refs {
+			if r.Name() == plumbing.HEAD && r.Type() == plumbing.SymbolicReference {
+				return &gitRef{name: r.Target()}, nil
+			}
+		}
+		// the server doesn't advertise the default branch
+		return &gitRef{}, nil
+	}
+
+	branch := plumbing.NewBranchReferenceName(ref)
+	tag := plumbing.NewTagReferenceName(ref)
+	tags := []plumbing.ReferenceName{}
+
+	for _, r := range refs {
+		switch r.Name() {
+		case branch, tag, plumbing.ReferenceName(ref):
+			logy.Debugf("resolved ref '%s' to '%s'", ref, r.Name())
+			return &gitRef{name: r.Name()}, nil
+		}
+		if r.Name().IsTag() {
+			tags = append(tags, r.Name())
+		}
+	}
+
+	// semver constraint e.g ">=1.2.0 <2.0.0" against all tags
+	if constraint, err := semver.ParseRange(ref); err == nil {
+		name, err := latestMatchingTag(tags, constraint)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolve version '%s'", ref)
+		}
+		logy.Debugf("resolved version '%s' to '%s'", ref, name)
+		return &gitRef{name: name}, nil
+	}
+
+	if commitHashPattern.MatchString(ref) {
+		return &gitRef{commit: ref}, nil
+	}
+
+	return nil, errors.Errorf("ref '%s' is neither a branch, tag, version constraint nor commit", ref)
+}
+
+// latestMatchingTag returns the tag with the highest version in the range
+func latestMatchingTag(tags []plumbing.ReferenceName, constraint semver.Range) (plumbing.ReferenceName, error) {
+	type version struct {
+		name    plumbing.ReferenceName
+		version semver.Version
+	}
+
+	versions := []version{}
+	for _, tag := range tags {
+		v, err := semver.Parse(strings.TrimPrefix(tag.Short(), "v"))
+		if err != nil {
+			continue
+		}
+		if constraint(v) {
+			versions = append(versions, version{tag, v})
+		}
+	}
+
+	if len(versions) == 0 {
+		return "", errors.New("no tag matches the version constraint")
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].version.GT(versions[j].version)
+	})
+
+	return versions[0].name, nil
+}
+
+// checkoutCommit checks out the commit by its full or abbreviated hash
+func checkoutCommit(repo *git.Repository, commit string) error {
+	hash := plumbing.NewHash(commit)
+
+	// abbreviated hashes has to be searched
+	if len(commit) < 40 {
+		iter, err := repo.CommitObjects()
+		if err != nil {
+			return errors.Wrap(err, "commit objects")
+		}
+		found := false
+		err = iter.ForEach(func(c *object.Commit) error {
+			if strings.HasPrefix(c.Hash.String(), commit) {
+				hash = c.Hash
+				found = true
+				return storer.ErrStop
+			}
+			return nil
+		})
+		if err != nil && err != storer.ErrStop {
+			return err
+		}
+		if !found {
+			return errors.Errorf("commit '%s' could not be found", commit)
+		}
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrap(err, "worktree")
+	}
+
+	err = w.Checkout(&git.CheckoutOptions{
+		Hash: hash,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "checkout commit '%s'", commit)
+	}
+
+	return nil
+}
@@ -0,0 +1,765 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/netzkern/butler/config"
+	"github.com/netzkern/butler/utils"
+	"github.com/pinzolo/casee"
+	"github.com/pkg/errors"
+	validator "gopkg.in/go-playground/validator.v9"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Severities of the lint issues
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+var (
+	yamlLinePattern     = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	yamlUnknownPattern  = regexp.MustCompile(`field (\S+) not found in type \S+`)
+	undefinedFnPattern  = regexp.MustCompile(`function "([^"]+)" not defined`)
+	validatorIdxPattern = regexp.MustCompile(`\.(Questions|AfterHooks|Generators)\[(\d+)\]`)
+)
+
+type (
+	// LintIssue is a problem of the template at a position
+	LintIssue struct {
+		File     string `json:"file"`
+		Line     int    `json:"line,omitempty"`
+		Column   int    `json:"column,omitempty"`
+		Severity string `json:"severity"`
+		Message  string `json:"message"`
+	}
+	// LintReport contains all issues of the template
+	LintReport struct {
+		Issues []LintIssue `json:"issues"`
+	}
+	// linter collects the issues of a template
+	linter struct {
+		t *Templating
+		// the raw survey file to find the lines of questions, hooks and generators
+		surveyDat []byte
+		// names of the partials and the templates they define
+		partials map[string]struct{}
+		report   *LintReport
+	}
+)
+
+// Errors returns the number of issues with the error severity
+func (r *LintReport) Errors() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Severity == LintError {
+			n++
+		}
+	}
+	return n
+}
+
+// Print prints the issues as file:line:column: severity: message
+func (r *LintReport) Print(output io.Writer) {
+	for _, issue := range r.Issues {
+		pos := issue.File
+		if issue.Line > 0 {
+			pos += ":" + strconv.Itoa(issue.Line)
+		}
+		if issue.Column > 0 {
+			pos += ":" + strconv.Itoa(issue.Column)
+		}
+		fmt.Fprintf(output, "%s: %s: %s\n", pos, issue.Severity, issue.Message)
+	}
+	fmt.Fprintf(output, "\n%d errors, %d warnings\n", r.Errors(), len(r.Issues)-r.Errors())
+}
+
+// PrintJSON prints the issues as json
+func (r *LintReport) PrintJSON(output io.Writer) error {
+	dat, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "lint report could not be marshaled")
+	}
+	_, err = fmt.Fprintln(output, string(dat))
+	return err
+}
+
+// add records an issue
+func (l *linter) add(file string, line, column int, severity, format string, args ...interface{}) {
+	l.report.Issues = append(l.report.Issues, LintIssue{
+		File:     filepath.ToSlash(file),
+		Line:     line,
+		Column:   column,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Lint checks the survey, the file names and the files of the template in the
+// directory without rendering it. Base templates are composed like in a run.
+func (t *Templating) Lint(dir string) (*LintReport, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "template abs failed")
+	}
+
+	l := &linter{t: t, partials: map[string]struct{}{}, report: &LintReport{Issues: []LintIssue{}}}
+
+	var s *Survey
+	if utils.Exists(filepath.Join(dir, t.configName)) {
+		s, err = l.lintSurveyFile(filepath.Join(dir, t.configName))
+		if err != nil {
+			return nil, err
+		}
+		// the files can't be checked without the questions
+		if s == nil {
+			return l.report, nil
+		}
+	}
+
+	tempDir, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temp folder failed")
+	}
+	defer os.RemoveAll(tempDir)
+
+	tpl := &config.Template{Name: filepath.Base(dir), URL: dir}
+	err = t.unpack(tpl, tempDir)
+	if err != nil {
+		l.add(".", 0, 0, LintError, "%s", err)
+		return l.report, nil
+	}
+
+	if s != nil {
+		composed, err := t.compose(s, tempDir, map[string]struct{}{templateKey(tpl): {}})
+		if err != nil {
+			l.add(t.configName, 0, 0, LintError, "compose template: %s", err)
+		} else {
+			s = composed
+		}
+		l.lintSurveyExpressions(s)
+	}
+	t.templateConfig = s
+
+	err = l.lintFiles(tempDir, s)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(l.report.Issues, func(i, j int) bool {
+		a, b := l.report.Issues[i], l.report.Issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+
+	return l.report, nil
+}
+
+// lintSurveyFile reports unknown keys, schema errors, duplicate names and defaults
+// which don't match their question. The survey is nil when it can't be read.
+func (l *linter) lintSurveyFile(path string) (*Survey, error) {
+	name := l.t.configName
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read survey config")
+	}
+	l.surveyDat = dat
+
+	s := &Survey{}
+	err = yaml.UnmarshalStrict(dat, s)
+	if err != nil {
+		messages := []string{err.Error()}
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			messages = typeErr.Errors
+		}
+		for _, msg := range messages {
+			line := 0
+			if m := yamlLinePattern.FindStringSubmatch(msg); m != nil {
+				line, _ = strconv.Atoi(m[1])
+				msg = m[2]
+			}
+			l.add(name, line, 0, LintError, "%s", yamlUnknownPattern.ReplaceAllString(msg, "unknown key '$1'"))
+		}
+
+		// unknown keys don't prevent the other checks
+		s = &Survey{}
+		if yaml.Unmarshal(dat, s) != nil {
+			return nil, nil
+		}
+	}
+
+	if err = validate(s); err != nil {
+		fieldErrs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			l.add(name, 0, 0, LintError, "%s", err)
+		}
+		for _, fe := range fieldErrs {
+			line := 0
+			if m := validatorIdxPattern.FindStringSubmatch(fe.Namespace()); m != nil {
+				i, _ := strconv.Atoi(m[2])
+				line = l.entryLine(s, m[1], i)
+			}
+			l.add(name, line, 0, LintError, "'%s' failed on the '%s' rule", strings.TrimPrefix(fe.Namespace(), "Survey."), fe.Tag())
+		}
+	}
+
+	l.lintNames("question", s.Questions)
+	l.lintHookNames(s.AfterHooks)
+	generators := map[string]struct{}{}
+	for _, g := range s.Generators {
+		if _, ok := generators[g.Name]; ok {
+			l.add(name, l.nameLine(g.Name, 1), 0, LintError, "duplicate generator '%s'", g.Name)
+		}
+		generators[g.Name] = struct{}{}
+		l.lintNames("question", g.Questions)
+		l.lintHookNames(g.AfterHooks)
+	}
+
+	seen := map[string]int{}
+	for _, q := range s.Questions {
+		l.lintDefault(q, seen[q.Name], l.getters(s.Questions))
+		seen[q.Name]++
+	}
+	for _, g := range s.Generators {
+		seen := map[string]int{}
+		for _, q := range g.Questions {
+			l.lintDefault(q, seen[q.Name], l.getters(s.Questions, g.Questions))
+			seen[q.Name]++
+		}
+	}
+
+	return s, nil
+}
+
+// entryLine returns the line of the question, hook or generator at the index
+func (l *linter) entryLine(s *Survey, list string, i int) int {
+	switch {
+	case list == "Questions" && i < len(s.Questions):
+		n := 0
+		for _, q := range s.Questions[:i] {
+			if q.Name == s.Questions[i].Name {
+				n++
+			}
+		}
+		return l.nameLine(s.Questions[i].Name, n)
+	case list == "AfterHooks" && i < len(s.AfterHooks):
+		n := 0
+		for _, h := range s.AfterHooks[:i] {
+			if h.Name == s.AfterHooks[i].Name {
+				n++
+			}
+		}
+		return l.nameLine(s.AfterHooks[i].Name, n)
+	case list == "Generators" && i < len(s.Generators):
+		return l.nameLine(s.Generators[i].Name, 0)
+	}
+	return 0
+}
+
+// nameLine returns the line of the n-th entry with the name in the survey file or 0
+func (l *linter) nameLine(name string, n int) int {
+	if name == "" {
+		return 0
+	}
+	pattern := regexp.MustCompile(`^\s*(?:-\s+)?name:\s*["']?` + regexp.QuoteMeta(name) + `["']?\s*$`)
+	for i, line := range strings.Split(string(l.surveyDat), "\n") {
+		if pattern.MatchString(line) {
+			if n == 0 {
+				return i + 1
+			}
+			n--
+		}
+	}
+	return 0
+}
+
+// keyLine returns the line of the first key with the name in the survey file or 0
+func (l *linter) keyLine(key string) int {
+	pattern := regexp.MustCompile(`^\s*["']?` + regexp.QuoteMeta(key) + `["']?\s*:`)
+	for i, line := range strings.Split(string(l.surveyDat), "\n") {
+		if pattern.MatchString(line) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// lintNames reports questions with the same name
+func (l *linter) lintNames(kind string, questions []Question) {
+	seen := map[string]int{}
+	for _, q := range questions {
+		if n, ok := seen[q.Name]; ok {
+			l.add(l.t.configName, l.nameLine(q.Name, n), 0, LintError, "duplicate %s '%s'", kind, q.Name)
+		}
+		seen[q.Name]++
+	}
+}
+
+// lintHookNames reports hooks with the same name
+func (l *linter) lintHookNames(hooks []Hook) {
+	seen := map[string]int{}
+	for _, h := range hooks {
+		if n, ok := seen[h.Name]; ok {
+			l.add(l.t.configName, l.nameLine(h.Name, n), 0, LintError, "duplicate hook '%s'", h.Name)
+		}
+		seen[h.Name]++
+	}
+}
+
+// lintDefault reports defaults which don't match the type, the options or the rules
+// of the question. Templated and dynamic values are only known in a run. n is the
+// occurrence of the question name to report duplicates at their own line.
+func (l *linter) lintDefault(q Question, n int, funcs template.FuncMap) {
+	if q.Default == nil || q.OptionsFrom != nil {
+		return
+	}
+	if s, ok := q.Default.(string); ok && isTemplateText(s, funcs) {
+		return
+	}
+	for _, o := range q.Options {
+		if isTemplateText(o, funcs) {
+			return
+		}
+	}
+
+	answer, err := normalizeAnswer(q, q.Default)
+	if err == nil {
+		err = validateRules(q, answer, nil, func(Question, interface{}, map[string]interface{}) (bool, error) {
+			return true, nil
+		})
+	}
+	if err != nil {
+		l.add(l.t.configName, l.nameLine(q.Name, n), 0, LintError, "default of question '%s': %s", q.Name, err)
+	}
+}
+
+// getters returns the template functions with the getters of the questions
+func (l *linter) getters(questions ...[]Question) template.FuncMap {
+	funcs := template.FuncMap{}
+	for k, v := range l.t.templateFuncMap {
+		funcs[k] = v
+	}
+	for _, list := range questions {
+		for _, q := range list {
+			funcs["get"+casee.ToPascalCase(q.Name)] = answerGetter(nil)
+			funcs["get"+casee.ToPascalCase(q.Name+"Question")] = answerGetter(nil)
+		}
+	}
+	funcs["include"] = func(string, interface{}) (string, error) { return "", nil }
+	return funcs
+}
+
+// lintSurveyExpressions parses the expressions, defaults, options, variables and
+// hook conditions of the survey
+func (l *linter) lintSurveyExpressions(s *Survey) {
+	l.lintExpressions(s.Questions, s.AfterHooks, s.Variables, l.getters(s.Questions))
+	for _, g := range s.Generators {
+		l.lintExpressions(g.Questions, g.AfterHooks, g.Variables, l.getters(s.Questions, g.Questions))
+	}
+}
+
+// lintExpressions parses the templates of the questions, hooks and variables
+func (l *linter) lintExpressions(questions []Question, hooks []Hook, variables map[string]interface{}, funcs template.FuncMap) {
+	seen := map[string]int{}
+	for _, q := range questions {
+		line := l.nameLine(q.Name, seen[q.Name])
+		seen[q.Name]++
+		for field, expr := range map[string]string{"when": q.When, "validate": q.Validate} {
+			if strings.TrimSpace(expr) != "" {
+				l.lintExpression(line, fmt.Sprintf("%s expression of question '%s'", field, q.Name), "{if "+expr+"}true{end}", funcs)
+			}
+		}
+
+		texts := append([]string{}, q.Options...)
+		if s, ok := q.Default.(string); ok {
+			texts = append(texts, s)
+		}
+		if src := q.OptionsFrom; src != nil {
+			texts = append(append(texts, src.File), src.Args...)
+		}
+		// texts with syntax errors are used literally e.g json. Texts which only fail
+		// because of undefined functions are likely a typo.
+		what := fmt.Sprintf("default or options of question '%s'", q.Name)
+		for _, text := range texts {
+			msgs := []string{}
+			tmpl := l.parse(what, text, startNameDelim, endNameDelim, funcs, func(_ int, msg string) {
+				msgs = append(msgs, msg)
+			})
+			if tmpl == nil {
+				continue
+			}
+			for _, msg := range msgs {
+				l.add(l.t.configName, line, 0, LintWarning, "%s is used literally: %s", what, msg)
+			}
+		}
+	}
+
+	seenHooks := map[string]int{}
+	for _, h := range hooks {
+		n := seenHooks[h.Name]
+		seenHooks[h.Name]++
+		if strings.TrimSpace(h.Enabled) != "" {
+			l.lintExpression(l.nameLine(h.Name, n), fmt.Sprintf("enabled expression of hook '%s'", h.Name), "{if "+h.Enabled+"}true{end}", funcs)
+		}
+	}
+
+	keys := make([]string, 0, len(variables))
+	for k := range variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if text, ok := variables[k].(string); ok {
+			l.lintExpression(l.keyLine(k), fmt.Sprintf("variable '%s'", k), text, funcs)
+		}
+	}
+}
+
+// lintExpression parses a template of the survey
+func (l *linter) lintExpression(line int, what, text string, funcs template.FuncMap) {
+	l.parse(what, text, startNameDelim, endNameDelim, funcs, func(_ int, msg string) {
+		l.add(l.t.configName, line, 0, LintError, "%s: %s", what, msg)
+	})
+}
+
+// parse parses the text and reports syntax errors and undefined functions with their
+// line. Undefined functions are replaced with stubs to find all of them.
+func (l *linter) parse(name, text, left, right string, funcs template.FuncMap, report func(line int, msg string)) *template.Template {
+	stubs := template.FuncMap{}
+	for {
+		tmpl, err := template.New(name).Delims(left, right).Funcs(funcs).Funcs(stubs).Parse(text)
+		if err == nil {
+			return tmpl
+		}
+
+		msg := strings.TrimPrefix(err.Error(), "template: "+name+":")
+		line := 0
+		if i := strings.Index(msg, ": "); i > 0 {
+			if n, convErr := strconv.Atoi(msg[:i]); convErr == nil {
+				line, msg = n, msg[i+2:]
+			}
+		}
+
+		m := undefinedFnPattern.FindStringSubmatch(msg)
+		if m == nil {
+			report(line, msg)
+			return nil
+		}
+		if strings.HasPrefix(m[1], "get") {
+			msg = fmt.Sprintf("undefined getter '%s', there is no question '%s'", m[1], casee.ToCamelCase(strings.TrimPrefix(m[1], "get")))
+		}
+		report(line, msg)
+		stubs[m[1]] = func(...interface{}) interface{} { return nil }
+	}
+}
+
+// lintFiles parses the partials, the names and the contents of the files of the
+// template and of the generators
+func (l *linter) lintFiles(root string, s *Survey) error {
+	if s == nil {
+		s = &Survey{}
+	}
+
+	partials := s.Partials
+	if partials == "" {
+		partials = DefaultPartialsDir
+	}
+	skipped := map[string]struct{}{
+		l.t.configName:           {},
+		IgnoreFilename:           {},
+		testsDir(s):              {},
+		GeneratorsDir:            {},
+		filepath.Clean(partials): {},
+	}
+	for _, g := range s.Generators {
+		skipped[filepath.Clean(generatorPath(g))] = struct{}{}
+	}
+	for p := range skipped {
+		if err := validateTemplatePath(p); err != nil {
+			l.add(l.t.configName, 0, 0, LintError, "%s", err)
+			return nil
+		}
+	}
+
+	variables := map[string]interface{}{}
+	for k, v := range l.t.Variables {
+		variables[k] = v
+	}
+	for k, v := range s.Variables {
+		variables[k] = v
+	}
+
+	funcs := l.getters(s.Questions)
+	err := l.lintPartials(root, partials, funcs, variables)
+	if err != nil {
+		return err
+	}
+
+	err = l.lintTree(root, "", s, skipped, funcs, variables)
+	if err != nil {
+		return err
+	}
+
+	for _, g := range s.Generators {
+		dir := generatorPath(g)
+		if !utils.Exists(filepath.Join(root, dir)) {
+			continue
+		}
+		gVariables := map[string]interface{}{}
+		for k, v := range variables {
+			gVariables[k] = v
+		}
+		for k, v := range g.Variables {
+			gVariables[k] = v
+		}
+		gSurvey := &Survey{
+			Exclude:          g.Exclude,
+			CopyOnly:         g.CopyOnly,
+			Include:          g.Include,
+			BinaryExtensions: s.BinaryExtensions,
+			TextExtensions:   s.TextExtensions,
+		}
+		err = l.lintTree(filepath.Join(root, dir), filepath.ToSlash(dir)+"/", gSurvey, nil, l.getters(s.Questions, g.Questions), gVariables)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lintPartials parses the partials and collects their names
+func (l *linter) lintPartials(root, dir string, funcs template.FuncMap, variables map[string]interface{}) error {
+	dir = filepath.Join(root, dir)
+	if !utils.Exists(dir) {
+		return nil
+	}
+
+	trees := []*template.Template{}
+	files := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		file, _ := filepath.Rel(root, path)
+		l.partials[strings.TrimSuffix(rel, filepath.Ext(rel))] = struct{}{}
+
+		dat, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tmpl := l.parse(file, string(dat), startContentDelim, endContentDelim, funcs, func(line int, msg string) {
+			l.add(file, line, 0, LintError, "%s", msg)
+		})
+		if tmpl != nil {
+			trees = append(trees, tmpl)
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "read partials")
+	}
+
+	// the templates which are defined inside of partials can be used as well
+	for _, tmpl := range trees {
+		for _, defined := range tmpl.Templates() {
+			l.partials[defined.Name()] = struct{}{}
+		}
+	}
+	for i, tmpl := range trees {
+		l.lintTemplate(files[i], tmpl, variables)
+	}
+
+	return nil
+}
+
+// lintTree parses the names and contents of the files which are rendered. The
+// prefix is prepended to the paths of the issues.
+func (l *linter) lintTree(root, prefix string, s *Survey, skipped map[string]struct{}, funcs template.FuncMap, variables map[string]interface{}) error {
+	rules, err := newFileRules(root, s)
+	if err != nil {
+		return err
+	}
+
+	l.t.fileActions = map[string]string{}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "walk failed")
+		}
+		if path == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if _, ok := skipped[rel]; ok {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		action := rules.match(rel, info.IsDir())
+		if action == fileActionIgnore {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		l.t.fileActions[path] = action
+
+		skipFile, skipDirErr := l.t.skip(path, info)
+		if skipFile || skipDirErr != nil {
+			return skipDirErr
+		}
+
+		file := prefix + filepath.ToSlash(rel)
+		l.parse(file, info.Name(), startNameDelim, endNameDelim, funcs, func(_ int, msg string) {
+			l.add(file, 0, 0, LintError, "name: %s", msg)
+		})
+		if info.IsDir() || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		dat, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tmpl := l.parse(file, string(dat), startContentDelim, endContentDelim, funcs, func(line int, msg string) {
+			l.add(file, line, 0, LintError, "%s", msg)
+		})
+		if tmpl != nil {
+			l.lintTemplate(file, tmpl, variables)
+		}
+
+		return nil
+	})
+}
+
+// lintTemplate reports references to undefined variables and partials
+func (l *linter) lintTemplate(file string, tmpl *template.Template, variables map[string]interface{}) {
+	defined := map[string]struct{}{}
+	for _, t := range tmpl.Templates() {
+		defined[t.Name()] = struct{}{}
+	}
+	partialExists := func(name string) bool {
+		_, ok := defined[name]
+		_, isPartial := l.partials[name]
+		return ok || isPartial
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		tree := t.Tree
+		position := func(n parse.Node) (int, int) {
+			location, _ := tree.ErrorContext(n)
+			parts := strings.Split(location, ":")
+			if len(parts) < 3 {
+				return 0, 0
+			}
+			line, _ := strconv.Atoi(parts[len(parts)-2])
+			col, _ := strconv.Atoi(parts[len(parts)-1])
+			return line, col
+		}
+
+		walkNodes(tree.Root, func(n parse.Node) {
+			var ident []string
+			switch node := n.(type) {
+			case *parse.FieldNode:
+				ident = node.Ident
+			case *parse.VariableNode:
+				if len(node.Ident) > 0 && node.Ident[0] == "$" {
+					ident = node.Ident[1:]
+				}
+			case *parse.TemplateNode:
+				if !partialExists(node.Name) {
+					line, col := position(n)
+					l.add(file, line, col, LintError, "undefined partial '%s'", node.Name)
+				}
+			case *parse.CommandNode:
+				if len(node.Args) < 2 {
+					return
+				}
+				fn, ok := node.Args[0].(*parse.IdentifierNode)
+				name, isString := node.Args[1].(*parse.StringNode)
+				if ok && isString && fn.Ident == "include" && !partialExists(name.Text) {
+					line, col := position(n)
+					l.add(file, line, col, LintError, "undefined partial '%s'", name.Text)
+				}
+			}
+
+			if len(ident) >= 2 && ident[0] == "Vars" {
+				if _, ok := variables[ident[1]]; !ok {
+					line, col := position(n)
+					l.add(file, line, col, LintWarning, "undefined variable '.Vars.%s'", ident[1])
+				}
+			}
+		})
+	}
+}
+
+// walkNodes calls the function for the node and all nodes below
+func walkNodes(node parse.Node, fn func(parse.Node)) {
+	fn(node)
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		for _, child := range n.Nodes {
+			walkNodes(child, fn)
+		}
+	case *parse.ActionNode:
+		walkNodes(n.Pipe, fn)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, fn)
+	case *parse.TemplateNode:
+		if n.Pipe != nil {
+			walkNodes(n.Pipe, fn)
+		}
+	case *parse.PipeNode:
+		for _, cmd := range n.Cmds {
+			walkNodes(cmd, fn)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkNodes(arg, fn)
+		}
+	case *parse.ChainNode:
+		walkNodes(n.Node, fn)
+	}
+}
+
+// walkBranch walks the pipeline and the lists of an if, range or with node
+func walkBranch(n *parse.BranchNode, fn func(parse.Node)) {
+	walkNodes(n.Pipe, fn)
+	walkNodes(n.List, fn)
+	if n.ElseList != nil {
+		walkNodes(n.ElseList, fn)
+	}
+}
@@ -0,0 +1,62 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintDuplicateQuestionLines(t *testing.T) {
+	survey := `questions:
+  - name: db
+    type: input
+    message: Database
+  - name: db
+    type: confirm
+    message: Database
+    default: maybe
+    when: "eq ("
+afterHooks:
+  - name: install
+    cmd: npm
+  - name: install
+    cmd: npm
+    enabled: "eq ("
+`
+
+	dir := testTempDir(t)
+	defer os.RemoveAll(dir)
+	writeTestFile(t, filepath.Join(dir, testSurveyFile), survey, 0644)
+
+	report, err := New(SetConfigName(testSurveyFile)).Lint(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		message string
+		line    int
+	}{
+		{"duplicate question 'db'", 5},
+		{"default of question 'db'", 5},
+		{"when expression of question 'db'", 5},
+		{"duplicate hook 'install'", 13},
+		{"enabled expression of hook 'install'", 13},
+	}
+
+	for _, tt := range tests {
+		found := false
+		for _, issue := range report.Issues {
+			if strings.Contains(issue.Message, tt.message) {
+				found = true
+				if issue.Line != tt.line {
+					t.Errorf("expected '%s' at line %d, got %d", issue.Message, tt.line, issue.Line)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("expected an issue '%s', got %+v", tt.message, report.Issues)
+		}
+	}
+}
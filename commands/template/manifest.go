@@ -0,0 +1,121 @@
+package template
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/netzkern/butler/config"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ManifestFilename is the name of the manifest inside generated projects
+const ManifestFilename = ".butler.yml"
+
+type (
+	// Manifest records which template and answers produced a project
+	Manifest struct {
+		Template      ManifestTemplate       `yaml:"template"`
+		ButlerVersion string                 `yaml:"butlerVersion"`
+		Project       CommandData            `yaml:"project"`
+		Answers       map[string]interface{} `yaml:"answers"`
+		Variables     map[string]interface{} `yaml:"variables"`
+		CreatedAt     time.Time              `yaml:"createdAt"`
+		UpdatedAt     *time.Time             `yaml:"updatedAt,omitempty"`
+		Render        *ManifestRender        `yaml:"render,omitempty"`
+		Generators    []ManifestGenerator    `yaml:"generators,omitempty"`
+	}
+	// ManifestGenerator records a generator which was run in the project
+	ManifestGenerator struct {
+		Name      string                 `yaml:"name"`
+		Answers   map[string]interface{} `yaml:"answers"`
+		CreatedAt time.Time              `yaml:"createdAt"`
+		Render    *ManifestRender        `yaml:"render,omitempty"`
+	}
+	// ManifestRender contains the seed and the time of a reproducible run
+	ManifestRender struct {
+		Seed *int64     `yaml:"seed,omitempty"`
+		Now  *time.Time `yaml:"now,omitempty"`
+	}
+	// ManifestTemplate contains the template source of the project
+	ManifestTemplate struct {
+		Name     string `yaml:"name"`
+		URL      string `yaml:"url"`
+		Ref      string `yaml:"ref,omitempty"`
+		Path     string `yaml:"path,omitempty"`
+		Commit   string `yaml:"commit,omitempty"`
+		Checksum string `yaml:"checksum,omitempty"`
+	}
+)
+
+// ReadManifest reads the manifest from the project directory
+func ReadManifest(dir string) (*Manifest, error) {
+	dat, err := ioutil.ReadFile(filepath.Join(dir, ManifestFilename))
+	if err != nil {
+		return nil, errors.Wrap(err, "read manifest")
+	}
+
+	manifest := &Manifest{}
+	err = yaml.Unmarshal(dat, manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "manifest could not be unmarshaled")
+	}
+
+	return manifest, nil
+}
+
+// WriteManifest writes the manifest into the project directory
+func WriteManifest(dir string, manifest *Manifest) error {
+	dat, err := yaml.Marshal(manifest)
+	if err != nil {
+		return errors.Wrap(err, "manifest could not be marshaled")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, ManifestFilename), dat, 0644)
+	if err != nil {
+		return errors.Wrap(err, "write manifest")
+	}
+
+	return nil
+}
+
+// newManifest creates the manifest of the current project. Answers of password
+// questions are never recorded.
+func (t *Templating) newManifest(tpl *config.Template) *Manifest {
+	answers := map[string]interface{}{}
+	for k, v := range t.surveyResult {
+		answers[k] = v
+	}
+	if t.templateConfig != nil {
+		for _, question := range t.templateConfig.Questions {
+			if question.Type == "password" {
+				delete(answers, question.Name)
+			}
+		}
+	}
+
+	return &Manifest{
+		Template: ManifestTemplate{
+			Name:     tpl.Name,
+			URL:      tpl.URL,
+			Ref:      tpl.Ref,
+			Path:     tpl.Path,
+			Commit:   t.commit,
+			Checksum: t.checksum,
+		},
+		ButlerVersion: t.butlerVersion.String(),
+		Project:       *t.CommandData,
+		Answers:       answers,
+		Variables:     t.Variables,
+		CreatedAt:     time.Now(),
+		Render:        t.manifestRender(),
+	}
+}
+
+// manifestRender returns the seed and the time of the run. They are recorded even
+// when they weren't fixed so the project can be rendered again with the same values.
+func (t *Templating) manifestRender() *ManifestRender {
+	seed, now := t.seed, t.now()
+	return &ManifestRender{Seed: &seed, Now: &now}
+}
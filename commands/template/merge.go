@@ -0,0 +1,259 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	conflictStart = "<<<<<<< "
+	conflictSep   = "======="
+	conflictEnd   = ">>>>>>> "
+
+	// maxDiffCells limits the memory of the line matching (lines a * lines b)
+	maxDiffCells = 16 * 1024 * 1024
+
+	diffContext = 3
+)
+
+// splitLines splits the text into lines, the line endings are preserved
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	// the last element is empty when the text ends with a line break
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// matchLines returns for every line in a the index of the matching line in b or -1.
+// The matching is based on the longest common subsequence.
+func matchLines(a, b []string) []int {
+	matches := make([]int, len(a))
+	for i := range matches {
+		matches[i] = -1
+	}
+
+	// skip common prefix and suffix to reduce the size of the table
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		matches[start] = start
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && a[endA-1] == b[endB-1] {
+		endA--
+		endB--
+		matches[endA] = endB
+	}
+
+	n, m := endA-start, endB-start
+	if n == 0 || m == 0 || n*m > maxDiffCells {
+		return matches
+	}
+
+	// lcs[i][j] is the length of the lcs of a[start+i:endA] and b[start+j:endB]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[start+i] == b[start+j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	for i, j := 0, 0; i < n && j < m; {
+		if a[start+i] == b[start+j] {
+			matches[start+i] = start + j
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return matches
+}
+
+// merge3 merges the changes from base to ours and from base to theirs.
+// Conflicting hunks are wrapped in conflict markers with the given labels.
+// It returns the merged text and whether a conflict occurred.
+func merge3(base, ours, theirs, oursLabel, theirsLabel string) (string, bool) {
+	baseLines, oursLines, theirsLines := splitLines(base), splitLines(ours), splitLines(theirs)
+	matchOurs := matchLines(baseLines, oursLines)
+	matchTheirs := matchLines(baseLines, theirsLines)
+
+	var out bytes.Buffer
+	conflict := false
+	i, a, b := 0, 0, 0
+
+	for {
+		// find the next base line which is stable in both versions
+		k := i
+		for k < len(baseLines) && (matchOurs[k] == -1 || matchTheirs[k] == -1) {
+			k++
+		}
+
+		endA, endB := len(oursLines), len(theirsLines)
+		if k < len(baseLines) {
+			endA, endB = matchOurs[k], matchTheirs[k]
+		}
+
+		baseChunk := strings.Join(baseLines[i:k], "")
+		oursChunk := strings.Join(oursLines[a:endA], "")
+		theirsChunk := strings.Join(theirsLines[b:endB], "")
+
+		switch {
+		case oursChunk == baseChunk:
+			out.WriteString(theirsChunk)
+		case theirsChunk == baseChunk, oursChunk == theirsChunk:
+			out.WriteString(oursChunk)
+		default:
+			conflict = true
+			out.WriteString(conflictStart + oursLabel + "\n")
+			out.WriteString(withNewline(oursChunk))
+			out.WriteString(conflictSep + "\n")
+			out.WriteString(withNewline(theirsChunk))
+			out.WriteString(conflictEnd + theirsLabel + "\n")
+		}
+
+		if k == len(baseLines) {
+			break
+		}
+
+		out.WriteString(baseLines[k])
+		i, a, b = k+1, endA+1, endB+1
+	}
+
+	return out.String(), conflict
+}
+
+// merge2 merges two versions without a common base. The common lines are the base
+// so lines which only exist in one version are kept and hunks which differ in both
+// versions are wrapped in conflict markers.
+func merge2(ours, theirs, oursLabel, theirsLabel string) (string, bool) {
+	oursLines := splitLines(ours)
+	matches := matchLines(oursLines, splitLines(theirs))
+
+	var base bytes.Buffer
+	for i, m := range matches {
+		if m != -1 {
+			base.WriteString(oursLines[i])
+		}
+	}
+
+	return merge3(base.String(), ours, theirs, oursLabel, theirsLabel)
+}
+
+// unifiedDiff returns the changes from a to b in the unified diff format
+func unifiedDiff(a, b, fromName, toName string) string {
+	aLines, bLines := splitLines(a), splitLines(b)
+	matches := matchLines(aLines, bLines)
+
+	type op struct {
+		kind byte
+		line string
+		a, b int
+	}
+
+	// build the edit script
+	ops := []op{}
+	i, j := 0, 0
+	for i < len(aLines) || j < len(bLines) {
+		switch {
+		case i < len(aLines) && matches[i] == -1:
+			ops = append(ops, op{'-', aLines[i], i, j})
+			i++
+		case i < len(aLines) && j == matches[i]:
+			ops = append(ops, op{' ', aLines[i], i, j})
+			i++
+			j++
+		default:
+			ops = append(ops, op{'+', bLines[j], i, j})
+			j++
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	for start := 0; start < len(ops); {
+		// find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// extend the hunk until the changes are separated by more than two contexts
+		first := start - diffContext
+		if first < 0 {
+			first = 0
+		}
+		end := start
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*diffContext {
+				break
+			}
+			end = next
+		}
+		last := end + diffContext
+		if last > len(ops) {
+			last = len(ops)
+		}
+
+		countA, countB := 0, 0
+		for _, o := range ops[first:last] {
+			if o.kind != '+' {
+				countA++
+			}
+			if o.kind != '-' {
+				countB++
+			}
+		}
+		// an empty range starts at the line before it
+		startA, startB := ops[first].a+1, ops[first].b+1
+		if countA == 0 {
+			startA--
+		}
+		if countB == 0 {
+			startB--
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", startA, countA, startB, countB)
+		for _, o := range ops[first:last] {
+			out.WriteByte(o.kind)
+			out.WriteString(withNewline(o.line))
+		}
+
+		start = last
+	}
+
+	return out.String()
+}
+
+// withNewline ensures that the text ends with a line break
+func withNewline(text string) string {
+	if text == "" || strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
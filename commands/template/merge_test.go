@@ -0,0 +1,192 @@
+package template
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		ours     string
+		theirs   string
+		want     string
+		conflict bool
+	}{
+		{
+			name:   "unchanged",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nb\nc\n",
+			want:   "a\nb\nc\n",
+		},
+		{
+			name:   "only ours",
+			base:   "a\nb\nc\n",
+			ours:   "a\nB\nc\n",
+			theirs: "a\nb\nc\n",
+			want:   "a\nB\nc\n",
+		},
+		{
+			name:   "only theirs",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nb\nC\n",
+			want:   "a\nb\nC\n",
+		},
+		{
+			name:   "both sides clean",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "A\nb\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			want:   "A\nb\nc\nd\nE\n",
+		},
+		{
+			name:   "same change",
+			base:   "a\nb\nc\n",
+			ours:   "a\nB\nc\n",
+			theirs: "a\nB\nc\n",
+			want:   "a\nB\nc\n",
+		},
+		{
+			name:     "overlapping edits",
+			base:     "a\nb\nc\n",
+			ours:     "a\nours\nc\n",
+			theirs:   "a\ntheirs\nc\n",
+			want:     "a\n<<<<<<< project\nours\n=======\ntheirs\n>>>>>>> template\nc\n",
+			conflict: true,
+		},
+		{
+			name:     "edit and delete",
+			base:     "a\nb\nc\n",
+			ours:     "a\nB\nc\n",
+			theirs:   "a\nc\n",
+			want:     "a\n<<<<<<< project\nB\n=======\n>>>>>>> template\nc\n",
+			conflict: true,
+		},
+		{
+			name:   "insert at start",
+			base:   "a\nb\n",
+			ours:   "a\nb\n",
+			theirs: "start\na\nb\n",
+			want:   "start\na\nb\n",
+		},
+		{
+			name:   "insert at end",
+			base:   "a\nb\n",
+			ours:   "a\nb\n",
+			theirs: "a\nb\nend\n",
+			want:   "a\nb\nend\n",
+		},
+		{
+			name:   "insert at start and end",
+			base:   "a\nb\n",
+			ours:   "start\na\nb\n",
+			theirs: "a\nb\nend\n",
+			want:   "start\na\nb\nend\n",
+		},
+		{
+			name:     "different inserts at start",
+			base:     "a\n",
+			ours:     "ours\na\n",
+			theirs:   "theirs\na\n",
+			want:     "<<<<<<< project\nours\n=======\ntheirs\n>>>>>>> template\na\n",
+			conflict: true,
+		},
+		{
+			name:     "different inserts at end",
+			base:     "a\n",
+			ours:     "a\nours\n",
+			theirs:   "a\ntheirs\n",
+			want:     "a\n<<<<<<< project\nours\n=======\ntheirs\n>>>>>>> template\n",
+			conflict: true,
+		},
+		{
+			name:   "missing final line break",
+			base:   "a\nb",
+			ours:   "A\nb",
+			theirs: "a\nb",
+			want:   "A\nb",
+		},
+		{
+			name:   "empty base",
+			base:   "",
+			ours:   "",
+			theirs: "a\n",
+			want:   "a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflict := merge3(tt.base, tt.ours, tt.theirs, "project", "template")
+			if got != tt.want {
+				t.Errorf("expected\n%s\ngot\n%s", tt.want, got)
+			}
+			if conflict != tt.conflict {
+				t.Errorf("expected conflict %v, got %v", tt.conflict, conflict)
+			}
+		})
+	}
+}
+
+func TestMerge2(t *testing.T) {
+	got, conflict := merge2("a\nours\nc\n", "a\ntheirs\nc\nd\n", "project", "template")
+	want := "a\n<<<<<<< project\nours\n=======\ntheirs\n>>>>>>> template\nc\nd\n"
+	if got != want || !conflict {
+		t.Errorf("expected conflict\n%s\ngot %v\n%s", want, conflict, got)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "equal",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "--- a\n+++ b\n",
+		},
+		{
+			name: "change",
+			a:    "a\nb\nc\n",
+			b:    "a\nB\nc\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "insert at start",
+			a:    "a\nb\n",
+			b:    "start\na\nb\n",
+			want: "--- a\n+++ b\n@@ -1,2 +1,3 @@\n+start\n a\n b\n",
+		},
+		{
+			name: "insert at end",
+			a:    "a\nb\n",
+			b:    "a\nb\nend\n",
+			want: "--- a\n+++ b\n@@ -1,2 +1,3 @@\n a\n b\n+end\n",
+		},
+		{
+			name: "new file",
+			a:    "",
+			b:    "a\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff(tt.a, tt.b, "a", "b")
+			if got != tt.want {
+				t.Errorf("expected\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
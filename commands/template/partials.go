@@ -0,0 +1,112 @@
+package template
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	logy "github.com/apex/log"
+	"github.com/pkg/errors"
+)
+
+// DefaultPartialsDir is the directory of the template with the partials
+const DefaultPartialsDir = "_partials"
+
+// loadPartials parses the files of the partials directory as named templates and
+// removes the directory from the project. A partial is named by its path relative
+// to the directory without the extension e.g "_partials/ci/node.yml" is "ci/node".
+func (t *Templating) loadPartials(root string) error {
+	dir := DefaultPartialsDir
+	if t.templateConfig != nil && t.templateConfig.Partials != "" {
+		dir = t.templateConfig.Partials
+	}
+	if err := validateTemplatePath(dir); err != nil {
+		return errors.Wrap(err, "invalid partials directory")
+	}
+
+	t.partials = template.New("").
+		Delims(startContentDelim, endContentDelim).
+		Funcs(t.templateFuncMap).
+		Funcs(template.FuncMap{
+			// replaced with the template of the file before it's executed
+			"include": func(name string, data interface{}) (string, error) {
+				return "", errors.New("include is only available in files")
+			},
+		})
+
+	dir = filepath.Join(root, dir)
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "read partials")
+	}
+	if !info.IsDir() {
+		return errors.Errorf("partials '%s' isn't a directory", info.Name())
+	}
+
+	sources := map[string]string{}
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "walk failed")
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		name := strings.TrimSuffix(rel, filepath.Ext(rel))
+		if other, ok := sources[name]; ok {
+			return errors.Errorf("partials '%s' and '%s' have the same name '%s'", other, rel, name)
+		}
+		sources[name] = rel
+
+		dat, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "read partial '%s'", rel)
+		}
+		_, err = t.partials.New(name).Parse(string(dat))
+		if err != nil {
+			return errors.Wrapf(err, "parse partial '%s'", rel)
+		}
+
+		logy.Debugf("partial '%s' loaded from %s", name, rel)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// partials aren't part of the project
+	return os.RemoveAll(dir)
+}
+
+// newFileTemplate returns a template for the file content with access to the partials.
+// The functions replace the functions of the partials.
+func (t *Templating) newFileTemplate(name string, funcs template.FuncMap) (*template.Template, error) {
+	partials, err := t.partials.Clone()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := partials.New(name)
+	partials.Funcs(funcs)
+	partials.Funcs(template.FuncMap{
+		// include renders the partial to a string which can be piped to other functions
+		"include": func(name string, data interface{}) (string, error) {
+			var buf bytes.Buffer
+			err := tmpl.ExecuteTemplate(&buf, name, data)
+			return buf.String(), err
+		},
+	})
+
+	return tmpl, nil
+}
@@ -0,0 +1,212 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	previewRenamed = "renamed"
+	previewRemoved = "removed"
+	previewSkipped = "skipped"
+	previewIgnored = "ignored"
+)
+
+type (
+	// Preview collects the changes of the templating process in the temp directory
+	// so they can be printed without a checkout. All methods are safe to call on a nil
+	// Preview and from multiple workers.
+	Preview struct {
+		root    string
+		mu      sync.Mutex
+		entries map[string]*previewEntry
+		errors  map[string]error
+	}
+
+	previewEntry struct {
+		status string
+		detail string
+		dir    bool
+	}
+
+	previewNode struct {
+		name     string
+		dir      bool
+		link     string
+		entry    *previewEntry
+		children map[string]*previewNode
+	}
+)
+
+// NewPreview create a preview for the given temp directory
+func NewPreview(root string) *Preview {
+	return &Preview{
+		root:    root,
+		entries: map[string]*previewEntry{},
+		errors:  map[string]error{},
+	}
+}
+
+// rel returns the path relative to the preview root
+func (p *Preview) rel(path string) string {
+	rel, err := filepath.Rel(p.root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (p *Preview) add(path, status, detail string, dir bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries[p.rel(path)] = &previewEntry{status, detail, dir}
+}
+
+// renamed marks the new path as renamed from the old path
+func (p *Preview) renamed(oldPath, newPath string) {
+	if p == nil {
+		return
+	}
+	p.add(newPath, previewRenamed, p.rel(oldPath), false)
+}
+
+// removed marks the path as removed because the template expression was evaluated to empty
+func (p *Preview) removed(path string, dir bool) {
+	p.add(path, previewRemoved, "", dir)
+}
+
+// ignored marks the path as dropped by the ignore rules of the template
+func (p *Preview) ignored(path string, dir bool) {
+	p.add(path, previewIgnored, "", dir)
+}
+
+// skipped marks the path as not processed by the template engine
+func (p *Preview) skipped(path, reason string) {
+	p.add(path, previewSkipped, reason, false)
+}
+
+// fail records a template error for the path
+func (p *Preview) fail(path string, err error) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.errors[p.rel(path)] = err
+}
+
+// Print walks the root directory and prints the resulting tree with all markers
+// followed by the template errors
+func (p *Preview) Print(output io.Writer, title string) error {
+	root := &previewNode{dir: true, children: map[string]*previewNode{}}
+
+	err := filepath.Walk(p.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == p.root {
+			return nil
+		}
+		node := root.insert(p.rel(path), info.IsDir(), p.entries[p.rel(path)])
+		if info.Mode()&os.ModeSymlink != 0 {
+			node.link, err = os.Readlink(path)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// removed paths doesn't exist anymore
+	for path, entry := range p.entries {
+		if entry.status == previewRemoved || entry.status == previewIgnored {
+			root.insert(path, entry.dir, entry)
+		}
+	}
+
+	fmt.Fprintln(output, title)
+	root.print(output, "")
+
+	if len(p.errors) > 0 {
+		paths := make([]string, 0, len(p.errors))
+		for path := range p.errors {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		fmt.Fprintln(output)
+		fmt.Fprintf(output, "Template errors (%d):\n", len(paths))
+		for _, path := range paths {
+			fmt.Fprintf(output, "  %s: %s\n", path, p.errors[path])
+		}
+	}
+
+	return nil
+}
+
+func (n *previewNode) insert(path string, dir bool, entry *previewEntry) *previewNode {
+	parts := strings.Split(path, "/")
+	node := n
+	for i, part := range parts {
+		child, ok := node.children[part]
+		if !ok {
+			child = &previewNode{name: part, dir: true, children: map[string]*previewNode{}}
+			node.children[part] = child
+		}
+		if i == len(parts)-1 {
+			child.dir = dir
+			child.entry = entry
+		}
+		node = child
+	}
+	return node
+}
+
+func (n *previewNode) print(output io.Writer, indent string) {
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		child := n.children[name]
+
+		branch, childIndent := "├── ", "│   "
+		if i == len(names)-1 {
+			branch, childIndent = "└── ", "    "
+		}
+
+		label := child.name
+		if child.dir {
+			label += "/"
+		}
+		if child.link != "" {
+			label += " -> " + child.link
+		}
+
+		if child.entry != nil {
+			switch child.entry.status {
+			case previewRenamed:
+				label += fmt.Sprintf(" [renamed from %s]", child.entry.detail)
+			case previewRemoved:
+				label += " [removed]"
+			case previewIgnored:
+				label += " [ignored]"
+			case previewSkipped:
+				label += fmt.Sprintf(" [skipped: %s]", child.entry.detail)
+			}
+		}
+
+		fmt.Fprintf(output, "%s%s%s\n", indent, branch, label)
+		child.print(output, indent+childIndent)
+	}
+}
@@ -0,0 +1,223 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+	survey "gopkg.in/AlecAivazis/survey.v1"
+	"gopkg.in/AlecAivazis/survey.v1/core"
+	surveyTerminal "gopkg.in/AlecAivazis/survey.v1/terminal"
+)
+
+type (
+	// pathPrompt is an input prompt with tab completion of file system paths
+	pathPrompt struct {
+		survey.Input
+	}
+	// listPrompt asks for free-text entries until an empty entry is given
+	listPrompt struct {
+		survey.Input
+		defaults []string
+	}
+	// mapPrompt asks for key/value pairs until an empty key is given
+	mapPrompt struct {
+		survey.Input
+		defaults map[string]string
+	}
+)
+
+// Prompt reads the path in raw mode to complete paths with the tab key
+func (p *pathPrompt) Prompt() (interface{}, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return p.Input.Prompt()
+	}
+
+	prompt, err := core.RunTemplate(survey.InputQuestionTemplate, survey.InputTemplateData{Input: p.Input})
+	if err != nil {
+		return "", err
+	}
+
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer terminal.Restore(fd, state)
+
+	term := terminal.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, prompt)
+	term.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		completed, candidates := completePath(line[:pos])
+		if len(candidates) > 1 {
+			fmt.Fprintf(term, "%s\n", strings.Join(candidates, "  "))
+		}
+		return completed + line[pos:], len(completed), true
+	}
+
+	line, err := term.ReadLine()
+	if err == io.EOF {
+		return "", surveyTerminal.InterruptErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if line == "" {
+		return p.Default, nil
+	}
+	return line, nil
+}
+
+// Cleanup keeps the line of the terminal
+func (p *pathPrompt) Cleanup(val interface{}) error {
+	return nil
+}
+
+// Error prints the validation error below the answer
+func (p *pathPrompt) Error(err error) error {
+	return printPromptError(err)
+}
+
+// completePath completes the path to the longest common prefix of all matching
+// entries and returns the names of the matching entries
+func completePath(prefix string) (string, []string) {
+	dir, base := filepath.Split(prefix)
+	listDir := dir
+	if listDir == "" {
+		listDir = "."
+	}
+
+	entries, err := ioutil.ReadDir(expandHome(listDir))
+	if err != nil {
+		return prefix, nil
+	}
+
+	candidates := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		// hidden entries are only completed when explicitly requested
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += string(filepath.Separator)
+		}
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+
+	if len(candidates) == 0 {
+		return prefix, nil
+	}
+
+	common := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	return dir + common, candidates
+}
+
+// Prompt asks for the entries of the list
+func (p *listPrompt) Prompt() (interface{}, error) {
+	list := []string{}
+	for {
+		msg := fmt.Sprintf("%s [%d] (empty to finish)", p.Message, len(list)+1)
+		if len(list) == 0 && len(p.defaults) > 0 {
+			msg = fmt.Sprintf("%s (empty for %s)", p.Message, strings.Join(p.defaults, ", "))
+		}
+
+		entry := ""
+		err := survey.AskOne(&survey.Input{Message: msg, Help: p.Help}, &entry, nil)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(entry) == "" {
+			break
+		}
+		list = append(list, entry)
+	}
+
+	if len(list) == 0 && len(p.defaults) > 0 {
+		return p.defaults, nil
+	}
+	return list, nil
+}
+
+// Cleanup keeps the asked entries
+func (p *listPrompt) Cleanup(val interface{}) error {
+	return nil
+}
+
+// Error prints the validation error below the entries
+func (p *listPrompt) Error(err error) error {
+	return printPromptError(err)
+}
+
+// Prompt asks for the keys and values of the map
+func (p *mapPrompt) Prompt() (interface{}, error) {
+	pairs := map[string]string{}
+	for {
+		msg := fmt.Sprintf("%s key (empty to finish)", p.Message)
+		if len(pairs) == 0 && len(p.defaults) > 0 {
+			msg = fmt.Sprintf("%s key (empty for defaults)", p.Message)
+		}
+
+		key := ""
+		err := survey.AskOne(&survey.Input{Message: msg, Help: p.Help}, &key, nil)
+		if err != nil {
+			return nil, err
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			break
+		}
+
+		value := ""
+		err = survey.AskOne(&survey.Input{Message: fmt.Sprintf("%s value of '%s'", p.Message, key)}, &value, nil)
+		if err != nil {
+			return nil, err
+		}
+		pairs[key] = value
+	}
+
+	if len(pairs) == 0 && len(p.defaults) > 0 {
+		return p.defaults, nil
+	}
+	return pairs, nil
+}
+
+// Cleanup keeps the asked pairs
+func (p *mapPrompt) Cleanup(val interface{}) error {
+	return nil
+}
+
+// Error prints the validation error below the pairs
+func (p *mapPrompt) Error(err error) error {
+	return printPromptError(err)
+}
+
+func printPromptError(err error) error {
+	out, tmplErr := core.RunTemplate(core.ErrorTemplate, err)
+	if tmplErr != nil {
+		return tmplErr
+	}
+	_, tmplErr = fmt.Fprint(os.Stdout, out)
+	return tmplErr
+}
@@ -0,0 +1,158 @@
+package template
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+)
+
+// IgnoreFilename is the name of the file with gitignore patterns of files which
+// aren't part of the project
+const IgnoreFilename = ".butlerignore"
+
+const (
+	// the file is dropped from the project
+	fileActionIgnore = "ignore"
+	// the file is copied without templating
+	fileActionCopyOnly = "copyOnly"
+	// the file is templated even if it's hidden, excluded or binary
+	fileActionInclude = "include"
+)
+
+// fileRules decides which files of the template are rendered, copied verbatim or dropped
+type fileRules struct {
+	ignore   gitignore.Matcher
+	copyOnly gitignore.Matcher
+	include  gitignore.Matcher
+}
+
+// newFileRules combines the patterns of the .butlerignore file with the rules of the survey
+func newFileRules(root string, s *Survey) (*fileRules, error) {
+	ignore, err := readIgnoreFile(filepath.Join(root, IgnoreFilename))
+	if err != nil {
+		return nil, err
+	}
+
+	var copyOnly, include []string
+	if s != nil {
+		ignore = append(ignore, s.Exclude...)
+		copyOnly = s.CopyOnly
+		include = s.Include
+	}
+
+	return &fileRules{
+		ignore:   newMatcher(ignore),
+		copyOnly: newMatcher(copyOnly),
+		include:  newMatcher(include),
+	}, nil
+}
+
+// readIgnoreFile returns the patterns of the file without comments and empty lines
+func readIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", IgnoreFilename)
+	}
+	defer f.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+
+	return patterns, scanner.Err()
+}
+
+func newMatcher(patterns []string) gitignore.Matcher {
+	ps := make([]gitignore.Pattern, 0, len(patterns))
+	for _, p := range patterns {
+		ps = append(ps, gitignore.ParsePattern(p, nil))
+	}
+	return gitignore.NewMatcher(ps)
+}
+
+// match returns the action for the path relative to the template root
+func (r *fileRules) match(rel string, dir bool) string {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+
+	switch {
+	case r.ignore.Match(parts, dir):
+		return fileActionIgnore
+	case r.copyOnly.Match(parts, dir):
+		return fileActionCopyOnly
+	case r.include.Match(parts, dir):
+		return fileActionInclude
+	}
+
+	return ""
+}
+
+// applyFileRules drops the ignored files and collects the actions of the other
+// files. It has to be called before any file or directory is renamed.
+func (t *Templating) applyFileRules(root string) error {
+	rules, err := newFileRules(root, t.templateConfig)
+	if err != nil {
+		return err
+	}
+
+	t.fileActions = map[string]string{}
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "walk failed")
+		}
+		if path == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		action := rules.match(rel, info.IsDir())
+		switch action {
+		case "":
+			return nil
+		case fileActionIgnore:
+			err = os.RemoveAll(path)
+			if err != nil {
+				return errors.Wrapf(err, "remove ignored '%s'", rel)
+			}
+			t.preview.ignored(path, info.IsDir())
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		t.fileActions[path] = action
+		// the content of the directory is copied as it is
+		if action == fileActionCopyOnly && info.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+}
+
+// renameFileActions updates the paths of the file actions after the directories were renamed
+func (t *Templating) renameFileActions() {
+	actions := make(map[string]string, len(t.fileActions))
+	for path, action := range t.fileActions {
+		actions[t.renamedPath(path)] = action
+	}
+	t.fileActions = actions
+}
@@ -17,6 +17,7 @@ type (
 
 	track struct {
 		name     string
+		note     string
 		start    time.Time
 		duration float64
 	}
@@ -31,7 +32,7 @@ func NewTaskTracker() *TaskTracker {
 
 // Track the duration of the task
 func (t *TaskTracker) Track(name string) {
-	t.tracks = append(t.tracks, &track{name, time.Now(), 0})
+	t.tracks = append(t.tracks, &track{name, "", time.Now(), 0})
 }
 
 // UnTrack measure the duration in seconds
@@ -44,6 +45,16 @@ func (t *TaskTracker) UnTrack(name string) {
 	}
 }
 
+// Annotate adds a note to the task which is shown in the summary e.g "cache hit"
+func (t *TaskTracker) Annotate(name, note string) {
+	for _, v := range t.tracks {
+		if v.name == name {
+			v.note = note
+			break
+		}
+	}
+}
+
 // PrintSummary print the summary on stdout
 func (t *TaskTracker) PrintSummary(output io.Writer) {
 	var totalDuration float64
@@ -55,7 +66,11 @@ func (t *TaskTracker) PrintSummary(output io.Writer) {
 	var headline, column string
 
 	for _, v := range t.tracks {
-		headline += fmt.Sprintf("%s\t", v.name)
+		if v.note != "" {
+			headline += fmt.Sprintf("%s (%s)\t", v.name, v.note)
+		} else {
+			headline += fmt.Sprintf("%s\t", v.name)
+		}
 		column += fmt.Sprintf("%s sec\t", strconv.FormatFloat(v.duration, 'f', 2, 64))
 	}
 
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
-	"math/rand"
 	"os"
 	"os/exec"
 	"path"
@@ -25,13 +24,20 @@ import (
 	"github.com/netzkern/butler/utils"
 	"github.com/pinzolo/casee"
 	"github.com/pkg/errors"
-	uuid "github.com/satori/go.uuid"
 	survey "gopkg.in/AlecAivazis/survey.v1"
 	git "gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 )
 
 var (
-	errManualTermination = errors.New("manual termination")
+	errManualTermination  = errors.New("manual termination")
+	errConfirmRequired    = errors.New("confirmation required in non-interactive mode, run with --yes")
+	projectNameValidators = survey.ComposeValidators(
+		survey.Required,
+		survey.MinLength(3),
+		survey.MaxLength(30),
+		projectNameValidator,
+	)
 )
 
 const (
@@ -47,10 +53,10 @@ const (
 type (
 	// CommandData contains all project data
 	CommandData struct {
-		Name        string
-		Path        string
-		Template    string
-		Description string
+		Name        string `yaml:"name"`
+		Path        string `yaml:"-"`
+		Template    string `yaml:"template"`
+		Description string `yaml:"description"`
 	}
 	// Templating command
 	Templating struct {
@@ -64,7 +70,8 @@ type (
 		excludedDirs    map[string]struct{}
 		excludedExts    map[string]struct{}
 		ch              chan func()
-		chErr           chan error
+		chErr           chan *TemplateError
+		templateErrors  []*TemplateError
 		wg              sync.WaitGroup
 		surveyResult    map[string]interface{}
 		templateFuncMap template.FuncMap
@@ -73,6 +80,29 @@ type (
 		dirRemovings    []string
 		cwd             string
 		butlerVersion   semver.Version
+		interactive     bool
+		autoConfirm     bool
+		dryRun          bool
+		preview         *Preview
+		commit          string
+		checksum        string
+		fileActions     map[string]string
+		revision        string
+		gitAuth         *config.GitAuth
+		cache           *Cache
+		templateDir     string
+		partials        *template.Template
+		generator       string
+		conflict        string
+		conflicts       []*fileConflict
+		rollback        bool
+		strict          bool
+		errorReport     string
+		preserveTimes   bool
+		now             func() time.Time
+		seed            int64
+		// answers of questions which aren't part of the survey are dropped
+		ignoreUnknownAnswers bool
 	}
 	// TemplateData basic template data
 	TemplateData struct {
@@ -88,51 +118,28 @@ type Option func(*Templating)
 
 // New with the given options.
 func New(options ...Option) *Templating {
+	// the clock is stopped at the start of the run so the time can be recorded
+	started := time.Now()
 	t := &Templating{
 		excludedDirs: toMap(ExcludedDirs),
 		excludedExts: toMap(BinaryFileExt),
 		// the buffer size is equivalent to the worker size this reduce the chance of wasted (blocking) resources.
 		ch:           make(chan func(), runtime.NumCPU()),
-		chErr:        make(chan error, runtime.NumCPU()),
+		chErr:        make(chan *TemplateError, runtime.NumCPU()),
 		dirRenamings: map[string]string{},
 		dirRemovings: []string{},
 		TaskTracker:  NewTaskTracker(),
+		interactive:  true,
+		rollback:     true,
+		now:          func() time.Time { return started },
+		seed:         started.UnixNano(),
 	}
 
 	for _, o := range options {
 		o(t)
 	}
 
-	t.templateFuncMap = template.FuncMap{
-		// string helper funcs
-		"toCamelCase":  casee.ToCamelCase,
-		"toPascalCase": casee.ToPascalCase,
-		"toSnakeCase":  casee.ToSnakeCase,
-		"toLowerCase":  strings.ToLower,
-		"toUpperCase":  strings.ToUpper,
-		"join":         strings.Join,
-		"replace":      strings.Replace,
-		"contains":     strings.Contains,
-		"index":        strings.Index,
-		"repeat":       strings.Repeat,
-		"split":        strings.Split,
-		// path
-		"joinPath": filepath.Join,
-		"relPath":  filepath.Rel,
-		"basePath": filepath.Base,
-		"extPath":  filepath.Ext,
-		"absPath":  filepath.Abs,
-		// regexp
-		"regex": func(str string) *regexp.Regexp {
-			return regexp.MustCompile(str)
-		},
-		// generators
-		"uuid":      uuid.NewV4,
-		"randomInt": func(min, max int) int { return rand.Intn(max-min) + min },
-		//environment
-		"cwd": func() string { return t.CommandData.Path },
-		"env": func(name string) string { return os.Getenv(name) },
-	}
+	t.templateFuncMap = t.newFuncMap()
 
 	return t
 }
@@ -179,6 +186,120 @@ func WithCommandData(cd *CommandData) Option {
 	}
 }
 
+// WithInteractive option.
+// When disabled the command data and survey results have to be passed as options
+// and are validated instead of prompted.
+func WithInteractive(b bool) Option {
+	return func(t *Templating) {
+		t.interactive = b
+	}
+}
+
+// WithAutoConfirm option.
+func WithAutoConfirm(b bool) Option {
+	return func(t *Templating) {
+		t.autoConfirm = b
+	}
+}
+
+// WithDryRun option.
+// The template is processed in the temp directory and the result is printed instead of
+// being checked out.
+func WithDryRun(b bool) Option {
+	return func(t *Templating) {
+		t.dryRun = b
+	}
+}
+
+// WithConflictPolicy option.
+// The policy applies to existing files in the destination which aren't matched by
+// the conflict rules of the template.
+func WithConflictPolicy(policy string) Option {
+	return func(t *Templating) {
+		t.conflict = policy
+	}
+}
+
+// WithRollback option.
+// The checkout is rolled back when a required after hook fails.
+func WithRollback(b bool) Option {
+	return func(t *Templating) {
+		t.rollback = b
+	}
+}
+
+// WithStrict option.
+// The run is aborted without a prompt when a file of the template can't be rendered.
+func WithStrict(b bool) Option {
+	return func(t *Templating) {
+		t.strict = b
+	}
+}
+
+// WithErrorReport option.
+// The errors of the files which can't be rendered are written as json to the file.
+func WithErrorReport(path string) Option {
+	return func(t *Templating) {
+		t.errorReport = path
+	}
+}
+
+// WithPreserveTimes option.
+// The modification times of the template files are kept in the project.
+func WithPreserveTimes(b bool) Option {
+	return func(t *Templating) {
+		t.preserveTimes = b
+	}
+}
+
+// WithClock option.
+// The clock is used for the date of the template data and the date functions.
+func WithClock(now func() time.Time) Option {
+	return func(t *Templating) {
+		t.now = now
+	}
+}
+
+// WithSeed option.
+// The seed of the random functions. The seed is recorded in the manifest.
+func WithSeed(seed int64) Option {
+	return func(t *Templating) {
+		t.seed = seed
+	}
+}
+
+// WithNow option.
+// The clock is stopped at the given time. The time is recorded in the manifest.
+func WithNow(now time.Time) Option {
+	return func(t *Templating) {
+		t.now = func() time.Time { return now }
+	}
+}
+
+// WithGitAuth option.
+// The global credentials are used for all templates without credentials.
+func WithGitAuth(auth *config.GitAuth) Option {
+	return func(t *Templating) {
+		t.gitAuth = auth
+	}
+}
+
+// WithRevision option.
+// The template is checked out at the given commit instead of the latest one.
+func WithRevision(hash string) Option {
+	return func(t *Templating) {
+		t.revision = hash
+	}
+}
+
+// WithCache option.
+// Remote templates are cloned from a mirror in the cache.
+func WithCache(c *Cache) Option {
+	return func(t *Templating) {
+		t.cache = c
+	}
+}
+
 // WithTemplateSurveyResults option.
 func WithTemplateSurveyResults(sr map[string]interface{}) Option {
 	return func(t *Templating) {
@@ -187,15 +308,92 @@ func WithTemplateSurveyResults(sr map[string]interface{}) Option {
 }
 
 // unpackGitRepository clone a repo to the dst
-func (t *Templating) unpackGitRepository(templatePath string, dest string) error {
-	logy.Debugf("unpack template from %s to %s", templatePath, dest)
+func (t *Templating) unpackGitRepository(tpl *config.Template, dest string) error {
+	logy.Debugf("unpack template from %s to %s", tpl.URL, dest)
 
-	_, err := git.PlainClone(dest, false, &git.CloneOptions{
-		URL: templatePath,
-	})
+	// the repository is cloned next to the destination when only a subdirectory is used
+	repoDir := dest
+	if tpl.Path != "" {
+		dir, err := ioutil.TempDir("", "butler")
+		if err != nil {
+			return errors.Wrap(err, "create temp folder failed")
+		}
+		defer os.RemoveAll(dir)
+		repoDir = dir
+	}
+
+	// the credentials are only resolved when the remote is accessed e.g a cached
+	// template can be used offline without ssh agent
+	authMethod := func() (transport.AuthMethod, error) {
+		return gitAuthMethod(tpl.URL, tpl.Auth, t.gitAuth)
+	}
+
+	// remote templates are cloned from the mirror in the cache
+	var auth transport.AuthMethod
+	source := tpl.URL
+	if t.cache != nil && !utils.Exists(tpl.URL) {
+		dir, hit, err := t.cache.mirror(tpl.URL, authMethod)
+		if err != nil {
+			return err
+		}
+		if hit {
+			t.TaskTracker.Annotate("Clone", "cache hit")
+		}
+		source = dir
+	} else {
+		var err error
+		auth, err = authMethod()
+		if err != nil {
+			return err
+		}
+	}
+
+	options := &git.CloneOptions{
+		URL:  source,
+		Auth: auth,
+	}
+
+	// the revision of an update takes precedence over the ref of the template
+	commit := t.revision
+	if commit == "" {
+		ref, err := resolveGitRef(source, tpl.Ref, &git.ListOptions{Auth: auth})
+		if err != nil {
+			return err
+		}
+		if ref.name != "" {
+			options.ReferenceName = ref.name
+			options.SingleBranch = true
+			// local repositories are cheap to clone
+			if !utils.Exists(source) {
+				options.Depth = 1
+			}
+		}
+		commit = ref.commit
+	}
 
+	repo, err := git.PlainClone(repoDir, false, options)
 	if err != nil {
-		return err
+		return gitError(tpl.URL, err)
+	}
+
+	if commit != "" {
+		err = checkoutCommit(repo, commit)
+		if err != nil {
+			return err
+		}
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return errors.Wrap(err, "resolve head")
+	}
+	t.commit = head.Hash().String()
+
+	if tpl.Path != "" {
+		err = utils.MoveDir(filepath.Join(repoDir, tpl.Path), dest, t.copyOptions()...)
+		if err != nil {
+			return errors.Wrapf(err, "template path '%s' could not be copied", tpl.Path)
+		}
 	}
 
 	// remove git files
@@ -207,15 +405,72 @@ func (t *Templating) unpackGitRepository(templatePath string, dest string) error
 	return err
 }
 
+// unpack copies the files of the template from the archive, the local directory
+// or the git repository to the dst
+func (t *Templating) unpack(tpl *config.Template, dest string) error {
+	var err error
+	switch {
+	case archiveFormat(tpl.URL) != "":
+		err = t.unpackArchive(tpl, dest)
+	// a specific revision or ref can only be checked out from a git repository
+	case utils.Exists(tpl.URL) && t.revision == "" && tpl.Ref == "":
+		err = t.unpackLocalGitRepository(tpl, dest)
+	default:
+		err = t.unpackGitRepository(tpl, dest)
+	}
+	if err != nil {
+		return err
+	}
+
+	return checkSymlinks(dest)
+}
+
+// checkSymlinks rejects symlinks of the template which point outside of the template
+func checkSymlinks(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "walk failed")
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		inside, err := utils.LinkInside(root, path)
+		if err != nil {
+			return errors.Wrapf(err, "resolve symlink '%s'", info.Name())
+		}
+		if !inside {
+			rel, _ := filepath.Rel(root, path)
+			link, _ := os.Readlink(path)
+			return errors.Errorf("symlink '%s' points to '%s' outside of the template", filepath.ToSlash(rel), link)
+		}
+
+		return nil
+	})
+}
+
+// copyOptions returns the options to copy the files of the template
+func (t *Templating) copyOptions() []utils.CopyOption {
+	return []utils.CopyOption{utils.WithPreserveTimes(t.preserveTimes)}
+}
+
 // unpackLocalGitRepository copy a local repository to the dst
-func (t *Templating) unpackLocalGitRepository(tempDir string, dest string) error {
-	logy.Debugf("unpack template from %s to %s", tempDir, dest)
+func (t *Templating) unpackLocalGitRepository(tpl *config.Template, dest string) error {
+	src := filepath.Join(tpl.URL, tpl.Path)
+	logy.Debugf("unpack template from %s to %s", src, dest)
 
-	err := utils.MoveDir(tempDir, dest)
+	err := utils.MoveDir(src, dest, t.copyOptions()...)
 	if err != nil {
 		return errors.Wrap(err, "local repository could not be copied")
 	}
 
+	// local templates aren't required to be git repositories
+	if repo, err := git.PlainOpen(tpl.URL); err == nil {
+		if head, err := repo.Head(); err == nil {
+			t.commit = head.Hash().String()
+		}
+	}
+
 	// remove git files
 	err = os.RemoveAll(filepath.Join(dest, ".git"))
 	if err != nil {
@@ -225,33 +480,84 @@ func (t *Templating) unpackLocalGitRepository(tempDir string, dest string) error
 	return err
 }
 
-func (t *Templating) packTemplate(tempDir, dest string) error {
-	// remove butler files
-	butlerSurveyFile := path.Clean(filepath.Join(tempDir, t.configName))
-	if utils.Exists(butlerSurveyFile) {
-		err := os.Remove(butlerSurveyFile)
-		if err != nil {
-			return errors.Wrap(err, "butler files could not be removed")
+// validateTemplatePath checks that the subdirectory of the template doesn't escape the repository
+func validateTemplatePath(p string) error {
+	if p == "" {
+		return nil
+	}
+	clean := filepath.Clean(p)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return errors.Errorf("template path '%s' must be relative to the repository", p)
+	}
+	return nil
+}
+
+// removeButlerFiles removes all files which are only used by butler and aren't part of the project
+func (t *Templating) removeButlerFiles(tempDir string) error {
+	for _, name := range []string{t.configName, IgnoreFilename} {
+		butlerFile := path.Clean(filepath.Join(tempDir, name))
+		if utils.Exists(butlerFile) {
+			err := os.Remove(butlerFile)
+			if err != nil {
+				return errors.Wrap(err, "butler files could not be removed")
+			}
 		}
 	}
 
-	logy.Debugf("pack template from %s to %s", tempDir, dest)
+	return nil
+}
 
-	err := utils.CreateDirIfNotExist(dest)
+// packTemplate writes the project to the destination. The returned checkout can
+// roll back the changes until it's cleaned.
+func (t *Templating) packTemplate(tempDir, dest string) (*checkout, error) {
+	err := t.removeButlerFiles(tempDir)
 	if err != nil {
-		return errors.Wrap(err, "create dest dir failed")
+		return nil, err
 	}
 
-	err = utils.MoveDir(tempDir, dest)
+	logy.Debugf("pack template from %s to %s", tempDir, dest)
+
+	co, err := newCheckout(dest, t.copyOptions()...)
+	if err != nil {
+		return nil, errors.Wrap(err, "inspect destination failed")
+	}
+	err = co.write(tempDir, t.conflicts)
 	if err != nil {
-		return errors.Wrap(err, "move failed")
+		return nil, errors.Wrap(err, "move failed")
 	}
 	err = os.RemoveAll(tempDir)
 	if err != nil {
-		return errors.Wrap(err, "remove all failed")
+		return nil, errors.Wrap(err, "remove all failed")
 	}
 
-	return nil
+	return co, nil
+}
+
+// rollbackCheckout undoes the checkout after a required hook failed. In interactive
+// mode the user decides whether the project is kept.
+func (t *Templating) rollbackCheckout(co *checkout) {
+	if t.rollback && t.interactive && !t.autoConfirm {
+		prompt := &survey.Confirm{
+			Message: fmt.Sprintf("Do you want to roll back the checkout to '%s' ?", co.dest),
+			Default: true,
+		}
+		rollback := true
+		err := survey.AskOne(prompt, &rollback, nil)
+		if err != nil {
+			logy.WithError(err).Error("confirm failed")
+		}
+		t.rollback = rollback
+	}
+
+	if !t.rollback {
+		logy.Warnf("the project in '%s' is kept", co.dest)
+		return
+	}
+
+	err := co.rollback()
+	if err != nil {
+		logy.WithError(err).Error("rollback failed")
+	}
 }
 
 func (t *Templating) cleanTemplate(tempDir string) error {
@@ -296,12 +602,7 @@ func (t *Templating) getQuestions() []*survey.Question {
 				Message: "What's the project name?",
 				Help:    "Allowed character [a-zA-Z0-9_-]{3,30}",
 			},
-			Validate: survey.ComposeValidators(
-				survey.Required,
-				survey.MinLength(3),
-				survey.MaxLength(30),
-				projectNameValidator,
-			),
+			Validate: projectNameValidators,
 		},
 		{
 			Name: "Description",
@@ -343,8 +644,22 @@ func (t *Templating) getTemplateQuestions() []*survey.Question {
 // skip returns an error when a directory should be skipped or true with a file
 func (t *Templating) skip(path string, info os.FileInfo) (bool, error) {
 	name := info.Name()
+
+	// rules of the template take precedence
+	switch t.fileActions[path] {
+	case fileActionCopyOnly:
+		t.preview.skipped(path, "copy only")
+		if info.IsDir() {
+			return false, filepath.SkipDir
+		}
+		return true, nil
+	case fileActionInclude:
+		return false, nil
+	}
+
 	// ignore hidden dirs and files
 	if len(name) > 1 && strings.HasPrefix(name, ".") {
+		t.preview.skipped(path, "hidden")
 		if info.IsDir() {
 			return false, filepath.SkipDir
 		}
@@ -355,14 +670,24 @@ func (t *Templating) skip(path string, info os.FileInfo) (bool, error) {
 	if info.IsDir() {
 		_, ok := t.excludedDirs[name]
 		if ok {
+			t.preview.skipped(path, "excluded")
 			return false, filepath.SkipDir
 		}
 	}
 
-	// skip blacklisted extensions
-	if !info.IsDir() {
-		_, ok := t.excludedExts[filepath.Ext("."+name)]
-		if ok {
+	// skip binary files, only the names of symlinks are rendered
+	if info.Mode().IsRegular() {
+		binary, reason, err := t.detectFileType(path)
+		if err != nil {
+			return false, errors.Wrapf(err, "detect file type of '%s'", name)
+		}
+		logy.WithFields(logy.Fields{
+			"path":   path,
+			"binary": binary,
+			"reason": reason,
+		}).Debug("detect file type")
+		if binary {
+			t.preview.skipped(path, "binary")
 			return true, nil
 		}
 	}
@@ -383,9 +708,20 @@ func (t *Templating) StartCommandSurvey() error {
 
 // startProjectSurvey ask the user for project details
 func (t *Templating) startProjectSurvey() error {
-	err := survey.Ask(t.getQuestions(), t.CommandData)
-	if err != nil {
-		return errors.Wrap(err, "command survey")
+	// generators use the project details of the manifest
+	if t.generator != "" {
+		return nil
+	}
+	if t.interactive {
+		err := survey.Ask(t.getQuestions(), t.CommandData)
+		if err != nil {
+			return errors.Wrap(err, "command survey")
+		}
+	} else {
+		err := t.validateCommandData()
+		if err != nil {
+			return errors.Wrap(err, "command data")
+		}
 	}
 	dest, err := filepath.Abs(t.CommandData.Path)
 	if err != nil {
@@ -395,7 +731,26 @@ func (t *Templating) startProjectSurvey() error {
 	return nil
 }
 
+// validateCommandData apply the same rules on the project details like the project survey
+func (t *Templating) validateCommandData() error {
+	if err := projectNameValidators(t.CommandData.Name); err != nil {
+		return errors.Wrapf(err, "invalid project name '%s'", t.CommandData.Name)
+	}
+	if strings.TrimSpace(t.CommandData.Path) == "" {
+		t.CommandData.Path = t.cwd
+	}
+	return nil
+}
+
 func (t *Templating) confirmPackTemplate(msg string) (bool, error) {
+	if t.autoConfirm {
+		logy.Debugf("auto confirm: %s", msg)
+		return true, nil
+	}
+	if !t.interactive {
+		return false, errConfirmRequired
+	}
+
 	packTemplate := false
 	prompt := &survey.Confirm{
 		Message: msg,
@@ -409,16 +764,75 @@ func (t *Templating) confirmPackTemplate(msg string) (bool, error) {
 	return packTemplate, nil
 }
 
+// knownAnswers returns the answers which are passed to the survey. The answers of
+// unknown questions are dropped when the command renders a project for an update
+// because the questions of the template can change between both versions.
+func (t *Templating) knownAnswers(s *Survey) map[string]interface{} {
+	if !t.ignoreUnknownAnswers {
+		return t.surveyResult
+	}
+
+	answers := map[string]interface{}{}
+	for _, question := range s.Questions {
+		if v, ok := t.surveyResult[question.Name]; ok {
+			answers[question.Name] = v
+		}
+	}
+	for name := range t.surveyResult {
+		if _, ok := answers[name]; !ok {
+			logy.Debugf("answer of unknown question '%s' is dropped", name)
+		}
+	}
+	return answers
+}
+
 func (t *Templating) startTemplateSurvey() error {
-	questions, err := BuildSurvey(t.templateConfig)
-	if err != nil {
-		return errors.Wrap(err, "build survey from template config")
+	if !t.interactive {
+		result, err := ValidateAnswers(t.templateConfig, t.knownAnswers(t.templateConfig), t.questionEnabled, t.resolveQuestion, t.answerValid)
+		if err != nil {
+			return err
+		}
+		t.surveyResult = result
+		logy.Debugf("survey results %+v", t.surveyResult)
+		return nil
 	}
 
+	// questions are asked one by one because the conditions, defaults and options
+	// depend on the previous answers
 	t.surveyResult = map[string]interface{}{}
-	err = survey.Ask(questions, &t.surveyResult)
-	if err != nil {
-		return errors.Wrap(err, "start template survey")
+
+	for _, question := range t.templateConfig.Questions {
+		enabled, err := t.questionEnabled(question, t.surveyResult)
+		if err != nil {
+			return err
+		}
+		question, err = t.resolveQuestion(question, t.surveyResult, enabled)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			v, err := normalizeAnswer(question, question.Default)
+			if err != nil {
+				return errors.Wrapf(err, "default of question '%s'", question.Name)
+			}
+			t.surveyResult[question.Name] = v
+			continue
+		}
+
+		sqs, err := buildQuestion(question, t.surveyResult, t.answerValid)
+		if err != nil {
+			return errors.Wrap(err, "build survey from template config")
+		}
+		err = survey.Ask([]*survey.Question{sqs}, &t.surveyResult)
+		if err != nil {
+			return errors.Wrap(err, "start template survey")
+		}
+
+		// the prompts return strings e.g for numbers
+		t.surveyResult[question.Name], err = normalizeAnswer(question, t.surveyResult[question.Name])
+		if err != nil {
+			return errors.Wrapf(err, "answer of question '%s'", question.Name)
+		}
 	}
 
 	logy.Debugf("survey results %+v", t.surveyResult)
@@ -426,6 +840,76 @@ func (t *Templating) startTemplateSurvey() error {
 	return nil
 }
 
+// questionEnabled evaluates the when condition of the question against the answers given so far
+func (t *Templating) questionEnabled(question Question, answers map[string]interface{}) (bool, error) {
+	if strings.TrimSpace(question.When) == "" {
+		return true, nil
+	}
+
+	enabled, err := parseStringAsTemplateCondition(t.TemplateData, t.answerFuncMap(answers), question.Name, question.When)
+	if err != nil {
+		return false, errors.Wrapf(err, "condition of question '%s'", question.Name)
+	}
+	if !enabled {
+		logy.Debugf("skip question '%s'", question.Name)
+	}
+
+	return enabled, nil
+}
+
+// answerValid evaluates the validate expression of the question. The answer is
+// accessible with the getter of the question.
+func (t *Templating) answerValid(question Question, answer interface{}, answers map[string]interface{}) (bool, error) {
+	funcMap := t.answerFuncMap(answers)
+	funcMap["get"+casee.ToPascalCase(question.Name)] = answerGetter(answer)
+
+	valid, err := parseStringAsTemplateCondition(t.TemplateData, funcMap, question.Name, question.Validate)
+	if err != nil {
+		return false, errors.Wrapf(err, "validate expression of question '%s'", question.Name)
+	}
+
+	return valid, nil
+}
+
+// answerFuncMap returns the template functions with getters for the answers
+func (t *Templating) answerFuncMap(answers map[string]interface{}) template.FuncMap {
+	funcMap := template.FuncMap{}
+	for k, v := range t.templateFuncMap {
+		funcMap[k] = v
+	}
+	for key, val := range answers {
+		funcMap["get"+casee.ToPascalCase(key)] = answerGetter(val)
+	}
+	return funcMap
+}
+
+// checkSurveyExpressions parses the when and validate expressions of all questions to
+// report syntax errors and unknown functions before the survey is started
+func (t *Templating) checkSurveyExpressions() error {
+	answers := map[string]interface{}{}
+	for _, question := range t.templateConfig.Questions {
+		answers[question.Name] = nil
+	}
+	funcMap := t.answerFuncMap(answers)
+
+	for _, question := range t.templateConfig.Questions {
+		for field, expr := range map[string]string{"when": question.When, "validate": question.Validate} {
+			if strings.TrimSpace(expr) == "" {
+				continue
+			}
+			_, err := template.New(question.Name).
+				Delims(startNameDelim, endNameDelim).
+				Funcs(funcMap).
+				Parse("{if " + expr + "}true{end}")
+			if err != nil {
+				return errors.Wrapf(err, "invalid %s expression of question '%s'", field, question.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
 // runSurveyTemplateHooks run all template hooks
 func (t *Templating) runSurveyTemplateHooks(cmdDir string) error {
 	for i, hook := range t.templateConfig.AfterHooks {
@@ -481,7 +965,7 @@ func (t *Templating) parseSurveyTemplateVariables() error {
 			if strings.TrimSpace(varString) == "" {
 				return nil
 			}
-			dat, err := parseStringAsTemplate(t.TemplateData, t.templateFuncMap, k, varString)
+			dat, err := parseStringAsTemplate(t.TemplateData, t.keyedFuncMap("variables/"+k), k, varString)
 			if err != nil {
 				return errors.Wrap(err, "parse variable template")
 			}
@@ -496,11 +980,7 @@ func (t *Templating) parseSurveyTemplateVariables() error {
 func (t *Templating) generateTempFuncs() {
 	// create getter functions for the survey results for easier access
 	for key, val := range t.surveyResult {
-		t.templateFuncMap["get"+casee.ToPascalCase(key)] = (func(v interface{}) func() interface{} {
-			return func() interface{} {
-				return v
-			}
-		})(val)
+		t.templateFuncMap["get"+casee.ToPascalCase(key)] = answerGetter(val)
 	}
 
 	// create getter functions for the survey options for easier access
@@ -514,6 +994,13 @@ func (t *Templating) generateTempFuncs() {
 
 }
 
+// answerGetter returns a template function which returns the answer
+func answerGetter(v interface{}) func() interface{} {
+	return func() interface{} {
+		return v
+	}
+}
+
 // walkDirectories run over all directories and collect renamed, removed items
 func (t *Templating) walkDirectories(path string, info os.FileInfo, err error) error {
 	ctx := logy.WithFields(logy.Fields{
@@ -542,16 +1029,19 @@ func (t *Templating) walkDirectories(path string, info os.FileInfo, err error) e
 	}
 
 	// Template directory
-	newDirectory, err := parseStringAsTemplate(t.TemplateData, t.templateFuncMap, path, info.Name())
+	newDirectory, err := parseStringAsTemplate(t.TemplateData, t.keyedFuncMap(t.randomKey(path)), path, info.Name())
+	// the directory is kept unprocessed like files whose name can't be rendered
 	if err != nil {
-		return errors.Wrap(err, "parse template for directory")
+		t.preview.fail(path, err)
+		t.chErr <- newTemplateError(t.templateDir, path, err, "directory name")
+		return nil
 	}
 
 	newPath := filepath.Join(filepath.Dir(path), newDirectory)
 
 	// when directory template expression was evaluated to empty
-	if strings.TrimSpace(newPath) == "" {
-		t.dirRemovings = append(t.dirRemovings, newPath)
+	if strings.TrimSpace(newDirectory) == "" {
+		t.dirRemovings = append(t.dirRemovings, path)
 	} else if path != newPath {
 		t.dirRenamings[path] = newPath
 	}
@@ -559,12 +1049,32 @@ func (t *Templating) walkDirectories(path string, info os.FileInfo, err error) e
 	return nil
 }
 
-// walkFiles run over all files and collect renamed items. Text files are proceed with the template engine.
-func (t *Templating) walkFiles(path string, info os.FileInfo, err error) error {
-	ctx := logy.WithFields(logy.Fields{
-		"path": path,
-		"size": info.Size(),
-		"dir":  info.IsDir(),
+// sortedDirRenamings returns the renamed directories, nested directories first
+func (t *Templating) sortedDirRenamings() []string {
+	oldPaths := make([]string, 0, len(t.dirRenamings))
+	for oldPath := range t.dirRenamings {
+		oldPaths = append(oldPaths, oldPath)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(oldPaths)))
+	return oldPaths
+}
+
+// renamedPath returns the path after all directory renamings were applied
+func (t *Templating) renamedPath(path string) string {
+	for _, oldPath := range t.sortedDirRenamings() {
+		if path == oldPath || strings.HasPrefix(path, oldPath+string(filepath.Separator)) {
+			path = t.dirRenamings[oldPath] + path[len(oldPath):]
+		}
+	}
+	return path
+}
+
+// walkFiles run over all files and collect renamed items. Text files are proceed with the template engine.
+func (t *Templating) walkFiles(path string, info os.FileInfo, err error) error {
+	ctx := logy.WithFields(logy.Fields{
+		"path": path,
+		"size": info.Size(),
+		"dir":  info.IsDir(),
 	})
 
 	if err != nil {
@@ -590,9 +1100,14 @@ func (t *Templating) walkFiles(path string, info os.FileInfo, err error) error {
 
 	// send job to workers
 	t.ch <- func() {
-		err := t.templater(path, info.Name(), ctx)
+		err := t.templater(path, info, ctx)
 		if err != nil {
-			t.chErr <- err
+			t.preview.fail(path, err)
+			templateErr, ok := err.(*TemplateError)
+			if !ok {
+				templateErr = newTemplateError(t.templateDir, path, err, "")
+			}
+			t.chErr <- templateErr
 		}
 	}
 
@@ -600,22 +1115,37 @@ func (t *Templating) walkFiles(path string, info os.FileInfo, err error) error {
 }
 
 // templater is responsible to parse files, rename or delete files and write the output back to the file.
+// The file mode is kept. Only the names of symlinks are rendered.
 // t.TemplateData and t.templateFuncMap are read-only
-func (t *Templating) templater(path, filename string, ctx *logy.Entry) error {
-	newFilename, err := parseStringAsTemplate(t.TemplateData, t.templateFuncMap, path, filename)
+func (t *Templating) templater(path string, info os.FileInfo, ctx *logy.Entry) error {
+	funcs := t.keyedFuncMap(t.randomKey(path))
+	newFilename, err := parseStringAsTemplate(t.TemplateData, funcs, path, info.Name())
 	if err != nil {
-		return errors.Wrap(err, "parse variable template")
+		return newTemplateError(t.templateDir, path, err, "file name")
 	}
 
 	newPath := filepath.Join(filepath.Dir(path), newFilename)
 
 	// when filename condition was evaluated to false
-	if strings.TrimSpace(newPath) == "" {
+	if strings.TrimSpace(newFilename) == "" {
 		err := os.Remove(path)
 		if err != nil {
 			ctx.WithError(err).Error("delete")
 			return err
 		}
+		t.preview.removed(path, false)
+		return nil
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		if path != newPath {
+			err := os.Rename(path, newPath)
+			if err != nil {
+				ctx.WithError(err).Error("rename")
+				return err
+			}
+			t.preview.renamed(path, newPath)
+		}
 		return nil
 	}
 
@@ -627,17 +1157,19 @@ func (t *Templating) templater(path, filename string, ctx *logy.Entry) error {
 	}
 
 	// Template file content
-	tmpl, err := template.New(newPath).
-		Delims(startContentDelim, endContentDelim).
-		Funcs(t.templateFuncMap).
-		Parse(string(dat))
+	tmpl, err := t.newFileTemplate(newPath, funcs)
+	if err != nil {
+		ctx.WithError(err).Error("partials")
+		return err
+	}
+	_, err = tmpl.Parse(string(dat))
 
 	if err != nil {
-		ctx.WithError(err).Error("parse")
+		ctx.WithError(err).Debug("parse")
 		return err
 	}
 
-	f, err := os.Create(newPath)
+	f, err := os.OpenFile(newPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
 
 	if err != nil {
 		ctx.WithError(err).Error("create")
@@ -649,10 +1181,24 @@ func (t *Templating) templater(path, filename string, ctx *logy.Entry) error {
 	err = tmpl.Execute(f, t.TemplateData)
 
 	if err != nil {
-		ctx.WithError(err).Error("template")
+		ctx.WithError(err).Debug("template")
 		return err
 	}
 
+	// the umask applies to new files
+	err = f.Chmod(info.Mode().Perm())
+	if err != nil {
+		ctx.WithError(err).Error("chmod")
+		return err
+	}
+	if t.preserveTimes {
+		err = os.Chtimes(newPath, info.ModTime(), info.ModTime())
+		if err != nil {
+			ctx.WithError(err).Error("chtimes")
+			return err
+		}
+	}
+
 	// remove old file when the name was changed
 	if path != newPath {
 		ctx.Debug("delete due to different filename")
@@ -661,6 +1207,7 @@ func (t *Templating) templater(path, filename string, ctx *logy.Entry) error {
 			ctx.WithError(err).Error("delete")
 			return err
 		}
+		t.preview.renamed(path, newPath)
 	}
 
 	return nil
@@ -700,6 +1247,10 @@ func (t *Templating) Run() (err error) {
 		logy.Debug("remove template artifacts")
 	}()
 
+	if t.dryRun {
+		t.preview = NewPreview(tempDir)
+	}
+
 	tpl := t.getTemplateByName(t.CommandData.Template)
 
 	if tpl == nil {
@@ -707,6 +1258,171 @@ func (t *Templating) Run() (err error) {
 		return err
 	}
 
+	errCount, err := t.render(tpl, tempDir)
+	if err != nil {
+		return err
+	}
+
+	printTemplateErrors(os.Stdout, t.templateErrors)
+	err = t.writeErrorReport(tpl.Name)
+	if err != nil {
+		return err
+	}
+	if t.strict && errCount > 0 {
+		err = errors.Errorf("template '%s' contains %d errors", tpl.Name, errCount)
+		return err
+	}
+
+	// the manifest is part of the preview and may conflict with an existing project
+	err = WriteManifest(tempDir, t.newManifest(tpl))
+	if err != nil {
+		logy.WithError(err).Error("write manifest")
+		return err
+	}
+
+	if t.dryRun {
+		err = t.removeButlerFiles(tempDir)
+		if err != nil {
+			return err
+		}
+		err = t.preview.Print(os.Stdout, fmt.Sprintf("Preview of '%s':", t.CommandData.Path))
+		if err != nil {
+			return errors.Wrap(err, "print preview")
+		}
+		conflicts, err := t.detectConflicts(tempDir, t.CommandData.Path)
+		if err != nil {
+			return errors.Wrap(err, "detect conflicts")
+		}
+		printConflicts(os.Stdout, t.CommandData.Path, conflicts)
+		return nil
+	}
+
+	var confirmMsg string
+	if errCount == 0 {
+		confirmMsg = fmt.Sprintf("Do you really want to checkout to '%s' ?", t.CommandData.Path)
+	} else if errCount == 1 {
+		confirmMsg = fmt.Sprintf("%s Do you really want to checkout to '%s' ?", fmt.Sprintf("We found %d error.", errCount), t.CommandData.Path)
+	} else {
+		confirmMsg = fmt.Sprintf("%s Do you really want to checkout to '%s' ?", fmt.Sprintf("We found %d errors.", errCount), t.CommandData.Path)
+	}
+
+	// all conflicts are resolved before anything is written
+	err = t.removeButlerFiles(tempDir)
+	if err != nil {
+		return err
+	}
+	t.conflicts, err = t.detectConflicts(tempDir, t.CommandData.Path)
+	if err != nil {
+		return errors.Wrap(err, "detect conflicts")
+	}
+	printConflicts(os.Stdout, t.CommandData.Path, t.conflicts)
+	err = t.resolveConflicts(tempDir, t.CommandData.Path, t.conflicts)
+	if err != nil {
+		return err
+	}
+
+	confirmed, err := t.confirmPackTemplate(confirmMsg)
+	if err != nil {
+		return err
+	}
+
+	if !confirmed {
+		err = errManualTermination
+		return err
+	}
+
+	co, err := t.packTemplate(tempDir, t.CommandData.Path)
+	if err != nil {
+		logy.WithError(err).Error("pack template failed")
+		return err
+	}
+	defer func() {
+		if err := co.clean(); err != nil {
+			logy.WithError(err).Error("clean checkout failed")
+		}
+	}()
+
+	/**
+	* Template Hook task
+	 */
+	t.TaskTracker.Track("After hooks")
+
+	if t.templateConfig != nil {
+		logy.Debug("execute template hooks")
+		err = t.runSurveyTemplateHooks(t.CommandData.Path)
+		if err != nil {
+			logy.WithError(err).Error("template hooks failed")
+			t.rollbackCheckout(co)
+			return err
+		}
+	} else {
+		logy.Debug("skip template hooks")
+	}
+
+	t.TaskTracker.UnTrack("After hooks")
+
+	/**
+	* Git hook task
+	 */
+	commandGitHook := githook.New(
+		githook.WithCwd(t.cwd),
+		githook.WithCommandData(
+			&githook.CommandData{
+				Path:  t.CommandData.Path,
+				Hooks: githook.Hooks,
+			},
+		),
+	)
+
+	err = commandGitHook.Run()
+	if err != nil {
+		logy.WithError(err).Error("could not create git hooks")
+		return err
+	}
+
+	t.TaskTracker.UnTrack("Git Hooks")
+
+	return err
+}
+
+// checkCompatibility checks the required butler version of the template
+func (t *Templating) checkCompatibility(templateConfig *Survey, ctx *logy.Entry) error {
+	if templateConfig.ButlerVersion != "" {
+		butlerVersions, err := semver.ParseRange(templateConfig.ButlerVersion)
+		if err != nil {
+			err := fmt.Errorf(
+				"could not parse required butler version '%s'",
+				templateConfig.ButlerVersion,
+			)
+			ctx.WithError(err).Error("invalid semver")
+			return err
+		}
+		if !butlerVersions(t.butlerVersion) {
+			err := fmt.Errorf(
+				"the required butler version '%s' does not match with your current version '%s'",
+				t.butlerVersion.String(),
+				templateConfig.ButlerVersion,
+			)
+			ctx.WithError(err).Error("template requirement")
+			return err
+		}
+	}
+
+	if templateConfig.Deprecated {
+		ctx.Infof("template is deprecated")
+	}
+
+	return nil
+}
+
+// render clones the template into the temp directory, starts all surveys and
+// process the directories and files. It returns the number of template errors.
+func (t *Templating) render(tpl *config.Template, tempDir string) (errCount int, err error) {
+	err = validateTemplatePath(tpl.Path)
+	if err != nil {
+		return 0, err
+	}
+
 	/**
 	* Clone task
 	 */
@@ -714,20 +1430,20 @@ func (t *Templating) Run() (err error) {
 	cloneSpinner := defaultSpinner("Cloning repository...")
 	cloneSpinner.Start()
 
-	if utils.Exists(tpl.URL) {
-		err = t.unpackLocalGitRepository(tpl.URL, tempDir)
-	} else {
-		err = t.unpackGitRepository(tpl.URL, tempDir)
-	}
+	err = t.unpack(tpl, tempDir)
 
 	t.TaskTracker.UnTrack("Clone")
 	cloneSpinner.Stop()
 
 	if err != nil {
 		logy.WithError(err).Error("clone")
-		return err
+		return 0, err
 	}
 
+	// commands and files of dynamic options are resolved in the template and the
+	// generators of the files are derived from their paths in it
+	t.templateDir = tempDir
+
 	surveyFilePath := path.Join(tempDir, t.configName)
 	ctx := logy.WithFields(logy.Fields{
 		"path": surveyFilePath,
@@ -738,33 +1454,37 @@ func (t *Templating) Run() (err error) {
 		templateConfig, err := ReadSurveyConfig(surveyFilePath)
 		if err != nil {
 			ctx.WithError(err).Error("read survey config")
-			return err
+			return 0, err
 		}
 
 		// check compatibility
-		if templateConfig.ButlerVersion != "" {
-			butlerVersions, err := semver.ParseRange(templateConfig.ButlerVersion)
-			if err != nil {
-				err := fmt.Errorf(
-					"could not parse required butler version '%s'",
-					templateConfig.ButlerVersion,
-				)
-				ctx.WithError(err).Error("invalid semver")
-				return err
-			}
-			if !butlerVersions(t.butlerVersion) {
-				err := fmt.Errorf(
-					"the required butler version '%s' does not match with your current version '%s'",
-					t.butlerVersion.String(),
-					templateConfig.ButlerVersion,
-				)
-				ctx.WithError(err).Error("template requirement")
-				return err
-			}
+		err = t.checkCompatibility(templateConfig, ctx)
+		if err != nil {
+			return 0, err
 		}
 
-		if templateConfig.Deprecated {
-			ctx.Infof("template is deprecated")
+		// the base templates are merged into the template
+		templateConfig, err = t.compose(templateConfig, tempDir, map[string]struct{}{templateKey(tpl): {}})
+		if err != nil {
+			ctx.WithError(err).Error("compose template")
+			return 0, err
+		}
+
+		// the test cases aren't part of the project
+		err = removeTests(templateConfig, tempDir)
+		if err != nil {
+			return 0, err
+		}
+
+		// the files of the generators aren't part of the project
+		if t.generator != "" {
+			templateConfig, err = t.selectGenerator(templateConfig, tempDir)
+		} else {
+			err = t.removeGenerators(templateConfig, tempDir)
+		}
+		if err != nil {
+			ctx.WithError(err).Error("generators")
+			return 0, err
 		}
 
 		// overwrite local variables with template variables
@@ -777,38 +1497,63 @@ func (t *Templating) Run() (err error) {
 
 		t.templateConfig = templateConfig
 
+		err = t.checkSurveyExpressions()
+		if err != nil {
+			ctx.WithError(err).Error("invalid template configuration")
+			return 0, err
+		}
+
 		err = t.startProjectSurvey()
 		if err != nil {
 			ctx.WithError(err).Error("start project survey")
-			return err
+			return 0, err
 		}
 
 		t.TemplateData = &TemplateData{
 			t.CommandData,
-			time.Now().Format(time.RFC3339),
-			time.Now().Year(),
+			t.now().Format(time.RFC3339),
+			t.now().Year(),
 			t.Variables,
 		}
 
 		err = t.startTemplateSurvey()
 		if err != nil {
 			ctx.WithError(err).Error("start template survey")
-			return err
+			return 0, err
 		}
 
 		t.generateTempFuncs()
 		t.parseSurveyTemplateVariables()
 
 	} else {
-		err := t.startProjectSurvey()
+		if t.generator != "" {
+			return 0, errors.Errorf("template '%s' has no generators", tpl.Name)
+		}
+
+		err := removeTests(nil, tempDir)
+		if err != nil {
+			return 0, err
+		}
+
+		err = t.startProjectSurvey()
 		if err != nil {
 			ctx.WithError(err).Error("start project survey")
-			return err
+			return 0, err
+		}
+
+		// all answers are unknown without a survey
+		if !t.interactive {
+			t.surveyResult, err = ValidateAnswers(&Survey{}, t.knownAnswers(&Survey{}), nil, nil, nil)
+			if err != nil {
+				ctx.WithError(err).Error("start template survey")
+				return 0, err
+			}
 		}
+
 		t.TemplateData = &TemplateData{
 			t.CommandData,
-			time.Now().Format(time.RFC3339),
-			time.Now().Year(),
+			t.now().Format(time.RFC3339),
+			t.now().Year(),
 			t.Variables,
 		}
 	}
@@ -820,11 +1565,48 @@ func (t *Templating) Run() (err error) {
 	// start multiple routines
 	t.startN(runtime.NumCPU())
 
+	// collect the template errors while the files are walked so that the
+	// workers are never blocked by a full error channel
+	collected := make(chan struct{})
+	go func() {
+		for err := range t.chErr {
+			t.templateErrors = append(t.templateErrors, err)
+		}
+		close(collected)
+	}()
+
+	// the workers, the collector and the spinner are stopped on every return
+	stopped := false
+	stopWorkers := func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		t.stop()
+		// it's blocked until chErr is closed
+		<-collected
+		templatingSpinner.Stop()
+	}
+	defer stopWorkers()
+
 	/**
 	* Templating task
 	 */
 	t.TaskTracker.Track("Template")
 
+	err = t.loadPartials(tempDir)
+	if err != nil {
+		logy.WithError(err).Error("partials")
+		return 0, err
+	}
+
+	// drop ignored files before their names are evaluated
+	err = t.applyFileRules(tempDir)
+	if err != nil {
+		logy.WithError(err).Error("file rules")
+		return 0, err
+	}
+
 	logy.Debugf("dir walk in path '%s'", tempDir)
 
 	// iterate through all directorys
@@ -833,27 +1615,34 @@ func (t *Templating) Run() (err error) {
 	if walkDirErr != nil {
 		logy.WithError(walkDirErr).Error("walk dir")
 		err = walkDirErr
-		return err
+		return 0, err
 	}
 
-	// rename and remove changed dirs from walk
-	for oldPath, newPath := range t.dirRenamings {
-		os.Rename(oldPath, newPath)
-		err = os.RemoveAll(oldPath)
+	// remove directories which are evaluated to empty string from walk
+	for _, path := range t.dirRemovings {
+		err = os.RemoveAll(path)
 		if err != nil {
 			logy.WithError(err).Error("remove all")
-			return err
+			return 0, err
 		}
+		t.preview.removed(t.renamedPath(path), true)
 	}
 
-	// remove directories which are evaluated to empty string from walk
-	for _, path := range t.dirRemovings {
-		err = os.RemoveAll(path)
+	// rename changed dirs from walk
+	// nested directories are renamed first so that the paths of their parents are still valid
+	for _, oldPath := range t.sortedDirRenamings() {
+		// parent was removed
+		if !utils.Exists(oldPath) {
+			continue
+		}
+		err = os.Rename(oldPath, t.dirRenamings[oldPath])
 		if err != nil {
-			logy.WithError(err).Error("remove all")
-			return err
+			logy.WithError(err).Error("rename")
+			return 0, err
 		}
+		t.preview.renamed(oldPath, t.renamedPath(oldPath))
 	}
+	t.renameFileActions()
 
 	logy.Debugf("file walk in path '%s'", tempDir)
 
@@ -861,89 +1650,17 @@ func (t *Templating) Run() (err error) {
 
 	if walkErr != nil {
 		err = walkErr
-		return err
+		return 0, err
 	}
 
-	go t.stop()
-
-	templatingSpinner.Stop()
+	stopWorkers()
 
 	t.TaskTracker.UnTrack("Template")
 
-	/**
-	* Let's collect all template errors
-	* It's blocked until chErr is closed
-	 */
-	var errCount int
-	for range t.chErr {
-		errCount++
-	}
-
-	var confirmMsg string
-	if errCount == 0 {
-		confirmMsg = fmt.Sprintf("Do you really want to checkout to '%s' ?", t.CommandData.Path)
-	} else if errCount == 1 {
-		confirmMsg = fmt.Sprintf("%s Do you really want to checkout to '%s' ?", fmt.Sprintf("We found %d error.", errCount), t.CommandData.Path)
-	} else {
-		confirmMsg = fmt.Sprintf("%s Do you really want to checkout to '%s' ?", fmt.Sprintf("We found %d errors.", errCount), t.CommandData.Path)
-	}
-
-	confirmed, err := t.confirmPackTemplate(confirmMsg)
-	if err != nil {
-		return err
-	}
-
-	if confirmed {
-		err = t.packTemplate(tempDir, t.CommandData.Path)
-		if err != nil {
-			logy.WithError(err).Error("pack template failed")
-			return err
-		}
-	} else {
-		err = errManualTermination
-		return err
-	}
-
-	/**
-	* Template Hook task
-	 */
-	t.TaskTracker.Track("After hooks")
-
-	if t.surveyResult != nil {
-		logy.Debug("execute template hooks")
-		err = t.runSurveyTemplateHooks(t.CommandData.Path)
-		if err != nil {
-			logy.WithError(err).Error("template hooks failed")
-			return err
-		}
-	} else {
-		logy.Debug("skip template hooks")
-	}
+	errCount = len(t.templateErrors)
+	sortTemplateErrors(t.templateErrors)
 
-	t.TaskTracker.UnTrack("After hooks")
-
-	/**
-	* Git hook task
-	 */
-	commandGitHook := githook.New(
-		githook.WithCwd(t.cwd),
-		githook.WithCommandData(
-			&githook.CommandData{
-				Path:  t.CommandData.Path,
-				Hooks: githook.Hooks,
-			},
-		),
-	)
-
-	err = commandGitHook.Run()
-	if err != nil {
-		logy.WithError(err).Error("could not create git hooks")
-		return err
-	}
-
-	t.TaskTracker.UnTrack("Git Hooks")
-
-	return err
+	return errCount, nil
 }
 
 // startN starts n loops.
@@ -1032,11 +1749,36 @@ func mapToEnvArray(s map[string]interface{}, prefix string) []string {
 			array = append(array, fmt.Sprintf("%s_%s=%s", prefix, envName, strings.Join(v, ",")))
 		case string:
 			array = append(array, fmt.Sprintf("%s_%s=%s", prefix, envName, a))
+		case bool, int, float64:
+			array = append(array, fmt.Sprintf("%s_%s=%v", prefix, envName, v))
+		case map[string]string:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			pairs := make([]string, 0, len(v))
+			for _, k := range keys {
+				pairs = append(pairs, k+"="+v[k])
+			}
+			array = append(array, fmt.Sprintf("%s_%s=%s", prefix, envName, strings.Join(pairs, ",")))
 		}
 	}
 	return array
 }
 
+// indent prefixes every non-empty line of the text with the number of spaces
+func indent(spaces int, text string) string {
+	pad := strings.Repeat(" ", spaces)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // toMap returns a map from slice.
 func toMap(s []string) map[string]struct{} {
 	m := make(map[string]struct{})
@@ -0,0 +1,241 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	logy "github.com/apex/log"
+)
+
+// oldTime is the modification time of the template files
+var oldTime = time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestTemplaterKeepsModes(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          string
+		mode          os.FileMode
+		want          string
+		preserveTimes bool
+	}{
+		{"executable", "gradlew", 0755, "gradlew", false},
+		{"regular", "readme.md", 0644, "readme.md", false},
+		{"renamed executable", "{ .Project.Name }.sh", 0755, "my-project.sh", false},
+		{"preserve times", "gradlew", 0755, "gradlew", true},
+		{"renamed preserve times", "{ .Project.Name }.sh", 0700, "my-project.sh", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testTempDir(t)
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, tt.file)
+			writeTestFile(t, path, "name=butler{ .Project.Name }", tt.mode)
+
+			command := newTestTemplating(t, dir, WithPreserveTimes(tt.preserveTimes))
+			info, err := os.Lstat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = command.templater(path, info, logy.WithField("path", path))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target := filepath.Join(dir, tt.want)
+			dat, err := ioutil.ReadFile(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(dat) != "name=my-project" {
+				t.Errorf("expected rendered content, got %q", dat)
+			}
+			info, err = os.Stat(target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.mode {
+				t.Errorf("expected mode %v, got %v", tt.mode, info.Mode().Perm())
+			}
+			if info.ModTime().Equal(oldTime) != tt.preserveTimes {
+				t.Errorf("expected preserved mtime %v, got %v", tt.preserveTimes, info.ModTime())
+			}
+		})
+	}
+}
+
+func TestTemplaterRenamesSymlinks(t *testing.T) {
+	dir := testTempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeTestFile(t, filepath.Join(dir, "docs", "readme.md"), "docs", 0644)
+	path := filepath.Join(dir, "{ .Project.Name }.md")
+	err := os.Symlink("docs/readme.md", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	command := newTestTemplating(t, dir)
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = command.templater(path, info, logy.WithField("path", path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := os.Readlink(filepath.Join(dir, "my-project.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link != "docs/readme.md" {
+		t.Errorf("expected the target to be kept, got '%s'", link)
+	}
+	dat, err := ioutil.ReadFile(filepath.Join(dir, "docs", "readme.md"))
+	if err != nil || string(dat) != "docs" {
+		t.Errorf("expected the target not to be rendered, got %q %v", dat, err)
+	}
+}
+
+func TestWalkDirectoriesCollectsErrors(t *testing.T) {
+	dir := testTempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeTestFile(t, filepath.Join(dir, "src", "{ nope }", "a.txt"), "", 0644)
+	writeTestFile(t, filepath.Join(dir, "{ .Project.Name }", "b.txt"), "", 0644)
+
+	command := newTestTemplating(t, dir)
+	err := filepath.Walk(dir, command.walkDirectories)
+	if err != nil {
+		t.Fatalf("expected the walk to continue, got %v", err)
+	}
+
+	select {
+	case e := <-command.chErr:
+		want := `src/{ nope }:1: directory name: function "nope" not defined`
+		if e.Error() != want {
+			t.Errorf("expected %q, got %q", want, e.Error())
+		}
+	default:
+		t.Fatal("expected a template error of the directory name")
+	}
+	if _, ok := command.dirRenamings[filepath.Join(dir, "{ .Project.Name }")]; !ok {
+		t.Errorf("expected the other directories to be renamed, got %v", command.dirRenamings)
+	}
+}
+
+func TestTemplaterIncludeErrors(t *testing.T) {
+	dir := testTempDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lib", "a.txt")
+	writeTestFile(t, path, `butler{ include "nope" . }`, 0644)
+
+	command := newTestTemplating(t, dir)
+	info, err := os.Lstat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = command.templater(path, info, logy.WithField("path", path))
+	if err == nil {
+		t.Fatal("expected an error of the missing partial")
+	}
+	templateErr := newTemplateError(dir, path, err, "")
+	if strings.Contains(templateErr.Message, dir) {
+		t.Errorf("expected the temporary directory to be stripped, got %q", templateErr.Message)
+	}
+	if !strings.Contains(templateErr.Message, `associated with template "lib/a.txt"`) {
+		t.Errorf("expected the path relative to the template, got %q", templateErr.Message)
+	}
+}
+
+func TestRenderStopsWorkers(t *testing.T) {
+	dir := testTempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeTestFile(t, filepath.Join(dir, testSurveyFile), "questions:\n  - name: db\n    type: input\n    message: Database\n", 0644)
+	writeTestFile(t, filepath.Join(dir, DefaultPartialsDir, "broken.txt"), "butler{ if }", 0644)
+	writeTestFile(t, filepath.Join(dir, DefaultTestsDir, "default.yml"), "files:\n  a.txt:\n", 0644)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 3; i++ {
+		report, err := New(SetConfigName(testSurveyFile), WithInteractive(false)).Test(dir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Failed() != 1 || !strings.Contains(report.Results[0].Errors[0], "parse partial") {
+			t.Fatalf("expected the broken partial to fail, got %+v", report.Results[0])
+		}
+	}
+
+	// the goroutines of the workers end shortly after they are stopped
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("expected the workers to be stopped, %d goroutines before and %d after", before, after)
+	}
+}
+
+// newTestTemplating returns a command which renders the files of the directory
+func newTestTemplating(t *testing.T, dir string, options ...Option) *Templating {
+	t.Helper()
+	command := New(append([]Option{WithSeed(1), WithNow(oldTime)}, options...)...)
+	command.templateDir = dir
+	command.TemplateData = &TemplateData{
+		Project: &CommandData{Name: "my-project"},
+		Vars:    map[string]interface{}{},
+	}
+	err := command.loadPartials(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return command
+}
+
+func testTempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// writeTestFile writes the file with the mode regardless of the umask and sets
+// the modification time to oldTime
+func writeTestFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(path, []byte(content), mode)
+	}
+	if err == nil {
+		err = os.Chmod(path, mode)
+	}
+	if err == nil {
+		err = os.Chtimes(path, oldTime, oldTime)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfirmPackTemplate(t *testing.T) {
+	confirmed, err := New(WithInteractive(false)).confirmPackTemplate("confirm?")
+	if err != errConfirmRequired || confirmed {
+		t.Errorf("expected %v without --yes, got %v, %v", errConfirmRequired, confirmed, err)
+	}
+
+	confirmed, err = New(WithInteractive(false), WithAutoConfirm(true)).confirmPackTemplate("confirm?")
+	if err != nil || !confirmed {
+		t.Errorf("expected confirmation with --yes, got %v, %v", confirmed, err)
+	}
+}
@@ -0,0 +1,412 @@
+package template
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	logy "github.com/apex/log"
+	"github.com/netzkern/butler/config"
+	"github.com/netzkern/butler/utils"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultTestsDir is the directory of the template with the test cases. It has a
+// leading underscore so it doesn't clash with a tests directory of the project.
+const DefaultTestsDir = "_tests"
+
+const (
+	// the project name of test cases without a name
+	defaultTestProject = "my-project"
+	// the seed of test cases without a seed
+	defaultTestSeed = 1
+)
+
+// defaultTestNow is the time of test cases without a time
+var defaultTestNow = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type (
+	// TestCase renders the template with the answers and compares the project with
+	// the expected files
+	TestCase struct {
+		Name      string                 `yaml:"name"`
+		Project   CommandData            `yaml:"project"`
+		Answers   map[string]interface{} `yaml:"answers"`
+		Variables map[string]interface{} `yaml:"variables"`
+		Seed      *int64                 `yaml:"seed"`
+		Now       string                 `yaml:"now"`
+		// directory of the expected project relative to the tests directory
+		Golden string `yaml:"golden"`
+		// expected contents of files, files without content only have to exist
+		Files map[string]*string `yaml:"files"`
+		// files which must not exist
+		Absent []string `yaml:"absent"`
+		// path of the test case file
+		file string
+		// the golden directory must exist because it was set or nothing else is checked
+		requireGolden bool
+	}
+	// TestResult contains the differences between the rendered and the expected project
+	TestResult struct {
+		Name    string
+		File    string
+		Errors  []string
+		Missing []string
+		Extra   []string
+		Diffs   []TestDiff
+		Updated bool
+	}
+	// TestDiff is a file with a different content
+	TestDiff struct {
+		Path string
+		Diff string
+	}
+	// TestReport contains the results of all test cases
+	TestReport struct {
+		Results []*TestResult
+	}
+)
+
+// Failed reports whether the rendered project differs from the expected project
+func (r *TestResult) Failed() bool {
+	return len(r.Errors) > 0 || len(r.Missing) > 0 || len(r.Extra) > 0 || len(r.Diffs) > 0
+}
+
+// Failed returns the number of failed test cases
+func (r *TestReport) Failed() int {
+	failed := 0
+	for _, result := range r.Results {
+		if result.Failed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Print prints the results of the test cases
+func (r *TestReport) Print(output io.Writer) {
+	for _, result := range r.Results {
+		status := "PASS"
+		switch {
+		case result.Failed():
+			status = "FAIL"
+		case result.Updated:
+			status = "UPDATED"
+		}
+		fmt.Fprintf(output, "%-7s %s (%s)\n", status, result.Name, result.File)
+
+		for _, e := range result.Errors {
+			fmt.Fprintf(output, "  error: %s\n", e)
+		}
+		for _, p := range result.Missing {
+			fmt.Fprintf(output, "  missing: %s\n", p)
+		}
+		for _, p := range result.Extra {
+			fmt.Fprintf(output, "  extra: %s\n", p)
+		}
+		for _, d := range result.Diffs {
+			fmt.Fprintf(output, "  diff: %s\n", d.Path)
+			fmt.Fprint(output, indent(4, d.Diff))
+		}
+	}
+
+	fmt.Fprintf(output, "\n%d passed, %d failed\n", len(r.Results)-r.Failed(), r.Failed())
+}
+
+// ParseTime parses a time in RFC3339 or the date format YYYY-MM-DD
+func ParseTime(s string) (time.Time, error) {
+	tm, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		tm, err = time.Parse("2006-01-02", s)
+	}
+	if err != nil {
+		return time.Time{}, errors.Errorf("invalid time '%s', expected RFC3339 or YYYY-MM-DD", s)
+	}
+	return tm, nil
+}
+
+// testsDir returns the directory of the test cases of the template
+func testsDir(s *Survey) string {
+	if s != nil && s.Tests != "" {
+		return s.Tests
+	}
+	return DefaultTestsDir
+}
+
+// removeTests removes the test cases from the template
+func removeTests(s *Survey, root string) error {
+	dir := testsDir(s)
+	if err := validateTemplatePath(dir); err != nil {
+		return errors.Wrap(err, "invalid tests directory")
+	}
+	err := os.RemoveAll(filepath.Join(root, dir))
+	if err != nil {
+		return errors.Wrapf(err, "remove test cases '%s'", dir)
+	}
+	return nil
+}
+
+// readTestCases reads the test cases of the template directory sorted by their file names
+func (t *Templating) readTestCases(dir string) (string, []*TestCase, error) {
+	var s *Survey
+	surveyFile := filepath.Join(dir, t.configName)
+	if utils.Exists(surveyFile) {
+		var err error
+		s, err = ReadSurveyConfig(surveyFile)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "read survey config")
+		}
+	}
+
+	tests := testsDir(s)
+	if err := validateTemplatePath(tests); err != nil {
+		return "", nil, errors.Wrap(err, "invalid tests directory")
+	}
+	tests = filepath.Join(dir, tests)
+
+	files, err := filepath.Glob(filepath.Join(tests, "*.yml"))
+	if err != nil {
+		return "", nil, err
+	}
+	sort.Strings(files)
+
+	cases := []*TestCase{}
+	for _, file := range files {
+		dat, err := ioutil.ReadFile(file)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "read test case")
+		}
+		c := &TestCase{}
+		err = yaml.UnmarshalStrict(dat, c)
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "test case '%s' could not be unmarshaled", filepath.Base(file))
+		}
+		c.file = file
+
+		base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if c.Name == "" {
+			c.Name = base
+		}
+		c.requireGolden = c.Golden != "" || (len(c.Files) == 0 && len(c.Absent) == 0)
+		// the golden directory is next to the test case by default
+		if c.Golden == "" {
+			c.Golden = base
+		}
+		if err := validateTemplatePath(c.Golden); err != nil {
+			return "", nil, errors.Wrapf(err, "invalid golden directory of test case '%s'", c.Name)
+		}
+		cases = append(cases, c)
+	}
+
+	return tests, cases, nil
+}
+
+// Test renders the template in the directory for every test case without prompts
+// and hooks and compares the projects with the expected files. The golden
+// directories are replaced with the rendered projects when update is enabled.
+func (t *Templating) Test(dir string, update bool) (*TestReport, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "template abs failed")
+	}
+
+	tests, cases, err := t.readTestCases(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, errors.Errorf("template '%s' has no test cases in '%s'", dir, tests)
+	}
+
+	tpl := &config.Template{Name: filepath.Base(dir), URL: dir}
+	report := &TestReport{}
+	for _, c := range cases {
+		logy.Debugf("run test case '%s'", c.Name)
+		result, err := t.runTestCase(tpl, tests, c, update)
+		if err != nil {
+			return nil, errors.Wrapf(err, "test case '%s'", c.Name)
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	return report, nil
+}
+
+// runTestCase renders the template with the answers of the test case and compares
+// the project with the expected files
+func (t *Templating) runTestCase(tpl *config.Template, tests string, c *TestCase, update bool) (*TestResult, error) {
+	rel, err := filepath.Rel(tpl.URL, c.file)
+	if err != nil {
+		return nil, err
+	}
+	result := &TestResult{Name: c.Name, File: filepath.ToSlash(rel)}
+
+	seed := int64(defaultTestSeed)
+	if c.Seed != nil {
+		seed = *c.Seed
+	}
+	now := defaultTestNow
+	if c.Now != "" {
+		now, err = ParseTime(c.Now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// only the variables of the survey and the test case are used so the result
+	// doesn't depend on the configuration of the user
+	variables := map[string]interface{}{}
+	for k, v := range c.Variables {
+		variables[k] = v
+	}
+	answers := map[string]interface{}{}
+	for k, v := range c.Answers {
+		answers[k] = v
+	}
+
+	cd := c.Project
+	if cd.Name == "" {
+		cd.Name = defaultTestProject
+	}
+	cd.Template = tpl.Name
+	cd.Path = cd.Name
+
+	tempDir, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temp folder failed")
+	}
+	defer t.cleanTemplate(tempDir)
+
+	command := New(
+		WithTemplates([]config.Template{*tpl}),
+		WithVariables(variables),
+		SetConfigName(t.configName),
+		WithButlerVersion(t.butlerVersion.String()),
+		WithCwd(t.cwd),
+		WithInteractive(false),
+		WithCommandData(&cd),
+		WithTemplateSurveyResults(answers),
+		WithSeed(seed),
+		WithNow(now),
+	)
+
+	_, err = command.render(tpl, tempDir)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result, nil
+	}
+	for _, e := range command.templateErrors {
+		result.Errors = append(result.Errors, e.Error())
+	}
+	if err = command.removeButlerFiles(tempDir); err != nil {
+		return nil, err
+	}
+
+	golden := filepath.Join(tests, c.Golden)
+	switch {
+	case update && len(result.Errors) == 0:
+		err = os.RemoveAll(golden)
+		if err == nil {
+			err = utils.CopyDir(tempDir, golden)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "update golden directory '%s'", c.Golden)
+		}
+		result.Updated = true
+	case utils.Exists(golden):
+		err = compareGolden(golden, tempDir, result)
+		if err != nil {
+			return nil, err
+		}
+	case c.requireGolden && !update:
+		result.Errors = append(result.Errors, fmt.Sprintf("golden directory '%s' missing, run with --update", c.Golden))
+	}
+
+	paths := make([]string, 0, len(c.Files))
+	for p := range c.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		dat, ok := readOptionalFile(filepath.Join(tempDir, filepath.FromSlash(p)))
+		if !ok {
+			result.Missing = append(result.Missing, p)
+			continue
+		}
+		expected := c.Files[p]
+		if expected != nil && *expected != string(dat) {
+			result.Diffs = append(result.Diffs, TestDiff{p, fileDiff([]byte(*expected), dat, p)})
+		}
+	}
+	for _, p := range c.Absent {
+		if _, err := os.Lstat(filepath.Join(tempDir, filepath.FromSlash(p))); err == nil {
+			result.Extra = append(result.Extra, p)
+		}
+	}
+
+	return result, nil
+}
+
+// compareGolden adds the files which are missing, extra or different in the project
+// compared to the golden directory to the result
+func compareGolden(golden, project string, result *TestResult) error {
+	expected, err := listFiles(golden)
+	if err != nil {
+		return errors.Wrap(err, "read golden directory")
+	}
+	actual, err := listFiles(project)
+	if err != nil {
+		return errors.Wrap(err, "read project")
+	}
+
+	rendered := map[string]struct{}{}
+	for _, rel := range actual {
+		rendered[rel] = struct{}{}
+	}
+
+	for _, rel := range expected {
+		p := filepath.ToSlash(rel)
+		if _, ok := rendered[rel]; !ok {
+			result.Missing = append(result.Missing, p)
+			continue
+		}
+		delete(rendered, rel)
+
+		goldenPath, projectPath := filepath.Join(golden, rel), filepath.Join(project, rel)
+		linkA, errA := os.Readlink(goldenPath)
+		linkB, errB := os.Readlink(projectPath)
+		if errA == nil || errB == nil {
+			if linkA != linkB {
+				result.Diffs = append(result.Diffs, TestDiff{p, fmt.Sprintf("symlink '%s' != '%s'\n", linkA, linkB)})
+			}
+			continue
+		}
+
+		want, _ := readOptionalFile(goldenPath)
+		got, _ := readOptionalFile(projectPath)
+		if string(want) != string(got) {
+			result.Diffs = append(result.Diffs, TestDiff{p, fileDiff(want, got, p)})
+		}
+	}
+
+	for rel := range rendered {
+		result.Extra = append(result.Extra, filepath.ToSlash(rel))
+	}
+	sort.Strings(result.Extra)
+
+	return nil
+}
+
+// fileDiff returns the unified diff of the expected and the rendered content
+func fileDiff(want, got []byte, rel string) string {
+	if isBinary(want) || isBinary(got) {
+		return "Binary files differ\n"
+	}
+	return unifiedDiff(string(want), string(got), "expected/"+rel, "rendered/"+rel)
+}
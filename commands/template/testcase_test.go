@@ -0,0 +1,86 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplatingTest(t *testing.T) {
+	tests := []struct {
+		name   string
+		cases  map[string]string
+		update bool
+		// expected errors of the test cases by name
+		errors map[string]string
+	}{
+		{
+			name:   "missing golden",
+			cases:  map[string]string{"default.yml": ""},
+			errors: map[string]string{"default": "golden directory 'default' missing, run with --update"},
+		},
+		{
+			name:   "missing explicit golden",
+			cases:  map[string]string{"default.yml": "golden: typo\nfiles:\n  app.txt:\n"},
+			errors: map[string]string{"default": "golden directory 'typo' missing, run with --update"},
+		},
+		{
+			name:  "files without golden",
+			cases: map[string]string{"default.yml": "files:\n  app.txt: \"none acme\"\n"},
+		},
+		{
+			name:   "update",
+			cases:  map[string]string{"default.yml": ""},
+			update: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testTempDir(t)
+			defer os.RemoveAll(dir)
+
+			writeTestFile(t, filepath.Join(dir, "app.txt"), `butler{ default "none" .Vars.secret } butler{ .Vars.company }`, 0644)
+			writeTestFile(t, filepath.Join(dir, testSurveyFile), "questions:\n  - name: db\n    type: input\n    message: Database\n    default: postgres\nvariables:\n  company: acme\n", 0644)
+			for name, content := range tt.cases {
+				writeTestFile(t, filepath.Join(dir, DefaultTestsDir, name), content, 0644)
+			}
+
+			// the variables of the user aren't used
+			command := New(
+				SetConfigName(testSurveyFile),
+				WithVariables(map[string]interface{}{"secret": "user"}),
+				WithInteractive(false),
+			)
+			report, err := command.Test(dir, tt.update)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, result := range report.Results {
+				want := tt.errors[result.Name]
+				got := strings.Join(result.Errors, "\n")
+				if got != want {
+					t.Errorf("expected errors %q of '%s', got %q", want, result.Name, got)
+				}
+				if result.Failed() != (want != "") {
+					t.Errorf("expected failed %v of '%s', got %+v", want != "", result.Name, result)
+				}
+			}
+
+			if tt.update {
+				report, err = command.Test(dir, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if report.Failed() != 0 {
+					t.Errorf("expected the updated golden directories to pass, got %+v", report.Results[0])
+				}
+			}
+		})
+	}
+}
+
+// testSurveyFile is the name of the survey of test templates
+const testSurveyFile = "butler-survey.yml"
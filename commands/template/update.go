@@ -0,0 +1,397 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	logy "github.com/apex/log"
+	"github.com/netzkern/butler/config"
+	"github.com/netzkern/butler/utils"
+	"github.com/pkg/errors"
+)
+
+// newFileSuffix is appended to the template version of files which can't be merged
+const newFileSuffix = ".butler-new"
+
+// UpdateSummary contains all paths which were touched by an update
+type UpdateSummary struct {
+	From       string
+	To         string
+	Changed    []string
+	Added      []string
+	Deleted    []string
+	Conflicted []string
+}
+
+// PrintSummary print the summary on stdout
+func (s *UpdateSummary) PrintSummary(output io.Writer) {
+	fmt.Fprintf(output, "Updated from %s to %s\n", shortHash(s.From), shortHash(s.To))
+
+	groups := []struct {
+		name  string
+		paths []string
+	}{
+		{"Changed", s.Changed},
+		{"Added", s.Added},
+		{"Deleted", s.Deleted},
+		{"Conflicted", s.Conflicted},
+	}
+
+	for _, g := range groups {
+		if len(g.paths) == 0 {
+			continue
+		}
+		sort.Strings(g.paths)
+		fmt.Fprintf(output, "%s (%d):\n", g.name, len(g.paths))
+		for _, p := range g.paths {
+			fmt.Fprintf(output, "  %s\n", p)
+		}
+	}
+}
+
+// Update re-applies the latest version of the template onto the project in the
+// path of the command data. The template is rendered at the commit of the manifest
+// and at the latest commit with the same answers. The difference is merged into the
+// project files. Conflicts are written with conflict markers or as ".rej" files
+// when rejectFiles is enabled.
+func (t *Templating) Update(rejectFiles bool) (summary *UpdateSummary, err error) {
+	projectDir, err := filepath.Abs(t.CommandData.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "project abs failed")
+	}
+
+	manifest, err := ReadManifest(projectDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if archiveFormat(manifest.Template.URL) != "" {
+		return nil, errors.Errorf("'%s' was created from an archive, only git templates can be updated", projectDir)
+	}
+
+	if manifest.Template.Commit == "" {
+		return nil, errors.Errorf("the manifest of '%s' contains no template commit", projectDir)
+	}
+
+	tpl := t.manifestTemplate(manifest)
+
+	oldDir, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temp folder failed")
+	}
+	defer t.cleanTemplate(oldDir)
+
+	newDir, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temp folder failed")
+	}
+	defer t.cleanTemplate(newDir)
+
+	base := t.fork(manifest, projectDir, manifest.Template.Commit)
+	errCount, err := base.render(tpl, oldDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "render template at project commit")
+	}
+	if err = base.removeButlerFiles(oldDir); err != nil {
+		return nil, err
+	}
+	if errCount > 0 {
+		printTemplateErrors(os.Stdout, base.templateErrors)
+		return nil, errors.Errorf("template at commit %s contains %d errors", shortHash(manifest.Template.Commit), errCount)
+	}
+
+	next := t.fork(manifest, projectDir, "")
+	errCount, err = next.render(tpl, newDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "render latest template")
+	}
+	if err = next.removeButlerFiles(newDir); err != nil {
+		return nil, err
+	}
+	if errCount > 0 {
+		printTemplateErrors(os.Stdout, next.templateErrors)
+		return nil, errors.Errorf("latest template contains %d errors", errCount)
+	}
+
+	if next.commit == manifest.Template.Commit {
+		logy.Infof("project is already up to date with commit %s", shortHash(next.commit))
+		return &UpdateSummary{From: manifest.Template.Commit, To: next.commit}, nil
+	}
+
+	confirmed, err := t.confirmPackTemplate(fmt.Sprintf(
+		"Do you really want to update '%s' from %s to %s ?",
+		projectDir,
+		shortHash(manifest.Template.Commit),
+		shortHash(next.commit),
+	))
+	if err != nil {
+		return nil, err
+	}
+	if !confirmed {
+		return nil, errManualTermination
+	}
+
+	summary, err = mergeProject(oldDir, newDir, projectDir, shortHash(next.commit), rejectFiles)
+	if err != nil {
+		return nil, err
+	}
+	summary.From = manifest.Template.Commit
+	summary.To = next.commit
+
+	updated := next.newManifest(tpl)
+	updated.CreatedAt = manifest.CreatedAt
+	updated.Generators = manifest.Generators
+	now := time.Now()
+	updated.UpdatedAt = &now
+
+	err = WriteManifest(projectDir, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// manifestTemplate returns the configured template of the manifest or a template
+// with the source of the manifest when it isn't configured anymore
+func (t *Templating) manifestTemplate(manifest *Manifest) *config.Template {
+	tpl := t.getTemplateByName(manifest.Template.Name)
+	if tpl == nil {
+		tpl = &config.Template{
+			Name:     manifest.Template.Name,
+			URL:      manifest.Template.URL,
+			Ref:      manifest.Template.Ref,
+			Path:     manifest.Template.Path,
+			Checksum: manifest.Template.Checksum,
+		}
+	}
+	return tpl
+}
+
+// fork creates a non-interactive command with the answers of the manifest to
+// render the template at the given revision
+func (t *Templating) fork(manifest *Manifest, projectDir, revision string) *Templating {
+	variables := map[string]interface{}{}
+	for k, v := range t.Variables {
+		variables[k] = v
+	}
+
+	// answers which aren't recorded e.g passwords can be passed by the user
+	answers := map[string]interface{}{}
+	for k, v := range manifest.Answers {
+		answers[k] = v
+	}
+	for k, v := range t.surveyResult {
+		answers[k] = v
+	}
+
+	cd := manifest.Project
+	cd.Path = projectDir
+
+	options := []Option{
+		WithTemplates(t.Templates),
+		WithVariables(variables),
+		SetConfigName(t.configName),
+		WithButlerVersion(t.butlerVersion.String()),
+		WithCwd(t.cwd),
+		WithInteractive(false),
+		WithCommandData(&cd),
+		WithTemplateSurveyResults(answers),
+		WithRevision(revision),
+		WithGitAuth(t.gitAuth),
+		WithCache(t.cache),
+	}
+
+	// the project is reproduced with the seed and the time it was rendered with.
+	// Values which weren't recorded are shared by all renderings of the update so
+	// random values don't show up as changes.
+	render := t.manifestRender()
+	if manifest.Render != nil && manifest.Render.Seed != nil {
+		render.Seed = manifest.Render.Seed
+	}
+	if manifest.Render != nil && manifest.Render.Now != nil {
+		render.Now = manifest.Render.Now
+	}
+	options = append(options, WithSeed(*render.Seed), WithNow(*render.Now))
+
+	// the recorded answers and the answers of the user can belong to questions
+	// which only exist in one version of the template
+	command := New(options...)
+	command.ignoreUnknownAnswers = true
+	return command
+}
+
+// mergeProject applies the changes between the old and new rendered template onto
+// the project directory
+func mergeProject(oldDir, newDir, projectDir, label string, rejectFiles bool) (*UpdateSummary, error) {
+	summary := &UpdateSummary{}
+
+	paths := map[string]struct{}{}
+	for _, dir := range []string{oldDir, newDir} {
+		files, err := listFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			paths[f] = struct{}{}
+		}
+	}
+
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	for _, rel := range sorted {
+		oldDat, inOld := readOptionalFile(filepath.Join(oldDir, rel))
+		newDat, inNew := readOptionalFile(filepath.Join(newDir, rel))
+		curDat, inCur := readOptionalFile(filepath.Join(projectDir, rel))
+		target := filepath.Join(projectDir, rel)
+
+		switch {
+		// unchanged in template or already applied
+		case inOld && inNew && bytes.Equal(oldDat, newDat):
+			continue
+		case inNew && inCur && bytes.Equal(curDat, newDat):
+			continue
+		case !inNew && !inCur:
+			continue
+		// deleted in template
+		case !inNew:
+			if bytes.Equal(curDat, oldDat) {
+				if err := os.Remove(target); err != nil {
+					return nil, errors.Wrapf(err, "delete '%s'", rel)
+				}
+				summary.Deleted = append(summary.Deleted, rel)
+			} else {
+				logy.Warnf("'%s' was deleted in the template but modified in the project", rel)
+				summary.Conflicted = append(summary.Conflicted, rel)
+			}
+		// added in template
+		case !inCur && !inOld:
+			if err := writeFileFrom(target, newDat, filepath.Join(newDir, rel)); err != nil {
+				return nil, err
+			}
+			summary.Added = append(summary.Added, rel)
+		// deleted in project
+		case !inCur:
+			logy.Warnf("'%s' was changed in the template but deleted in the project", rel)
+			summary.Conflicted = append(summary.Conflicted, rel)
+		// unchanged in project
+		case inOld && bytes.Equal(curDat, oldDat):
+			if err := writeFileFrom(target, newDat, filepath.Join(newDir, rel)); err != nil {
+				return nil, err
+			}
+			summary.Changed = append(summary.Changed, rel)
+		default:
+			conflict, err := mergeFile(target, rel, oldDat, curDat, newDat, label, rejectFiles)
+			if err != nil {
+				return nil, err
+			}
+			if conflict {
+				summary.Conflicted = append(summary.Conflicted, rel)
+			} else {
+				summary.Changed = append(summary.Changed, rel)
+			}
+		}
+	}
+
+	return summary, nil
+}
+
+// mergeFile merges the template changes into a file which was modified in the project.
+// It returns true when the changes couldn't be merged without a conflict.
+func mergeFile(target, rel string, oldDat, curDat, newDat []byte, label string, rejectFiles bool) (bool, error) {
+	// binary files can't be merged, the version of the template is written next to
+	// the project file
+	if isBinary(oldDat) || isBinary(curDat) || isBinary(newDat) {
+		logy.Warnf("binary file '%s' was modified in the template and the project, the template version is written to '%s%s'", rel, rel, newFileSuffix)
+		err := ioutil.WriteFile(target+newFileSuffix, newDat, 0644)
+		if err != nil {
+			return true, errors.Wrapf(err, "write template version of '%s'", rel)
+		}
+		return true, nil
+	}
+
+	merged, conflict := merge3(string(oldDat), string(curDat), string(newDat), "project", "template "+label)
+
+	if conflict && rejectFiles {
+		diff := unifiedDiff(string(oldDat), string(newDat), "a/"+filepath.ToSlash(rel), "b/"+filepath.ToSlash(rel))
+		err := ioutil.WriteFile(target+".rej", []byte(diff), 0644)
+		if err != nil {
+			return true, errors.Wrapf(err, "write reject file of '%s'", rel)
+		}
+		return true, nil
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return conflict, err
+	}
+	err = ioutil.WriteFile(target, []byte(merged), info.Mode())
+	if err != nil {
+		return conflict, errors.Wrapf(err, "write '%s'", rel)
+	}
+
+	return conflict, nil
+}
+
+// listFiles returns the relative paths of all files in the directory
+func listFiles(dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == ManifestFilename {
+			return nil
+		}
+		files = append(files, rel)
+		return nil
+	})
+	return files, err
+}
+
+// readOptionalFile returns the content and true when the file exists
+func readOptionalFile(path string) ([]byte, bool) {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	return dat, true
+}
+
+// writeFileFrom writes the content with the file mode of the source file
+func writeFileFrom(target string, dat []byte, source string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	err = utils.CreateDirIfNotExist(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(target, dat, info.Mode())
+}
+
+// shortHash returns the abbreviated commit hash
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
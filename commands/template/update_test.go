@@ -0,0 +1,149 @@
+package template
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netzkern/butler/config"
+)
+
+func TestForkSeedAndClock(t *testing.T) {
+	seed := int64(42)
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		render *ManifestRender
+		seed   int64
+		now    time.Time
+	}{
+		{"recorded", &ManifestRender{Seed: &seed, Now: &now}, seed, now},
+		{"only seed", &ManifestRender{Seed: &seed}, seed, oldTime},
+		{"not recorded", nil, 1, oldTime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := New(WithSeed(1), WithNow(oldTime))
+			manifest := &Manifest{Render: tt.render}
+
+			base := command.fork(manifest, "base", "v1")
+			next := command.fork(manifest, "next", "v2")
+			for _, fork := range []*Templating{base, next} {
+				if fork.seed != tt.seed {
+					t.Errorf("expected seed %d, got %d", tt.seed, fork.seed)
+				}
+				if !fork.now().Equal(tt.now) {
+					t.Errorf("expected time %v, got %v", tt.now, fork.now())
+				}
+			}
+		})
+	}
+}
+
+func TestManifestRender(t *testing.T) {
+	command := New()
+	render := command.manifestRender()
+	if render.Seed == nil || render.Now == nil {
+		t.Fatalf("expected the seed and the time to be recorded, got %+v", render)
+	}
+	if *render.Seed != command.seed || !render.Now.Equal(command.now()) {
+		t.Errorf("expected the seed %d and the time %v of the run, got %+v", command.seed, command.now(), render)
+	}
+}
+
+func TestUpdateChangedQuestions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+
+	repoDir, repo := initTestRepo(t)
+	defer os.RemoveAll(repoDir)
+	commitTestFile(t, repo, repoDir, testSurveyFile, "questions:\n  - name: removed\n    type: input\n    message: Removed\n")
+	base := commitTestFile(t, repo, repoDir, "a.txt", "butler{ getRemoved }\n")
+
+	projectDir := testTempDir(t)
+	defer os.RemoveAll(projectDir)
+	writeTestFile(t, filepath.Join(projectDir, "a.txt"), "old\n", 0644)
+	err := WriteManifest(projectDir, &Manifest{
+		Template: ManifestTemplate{Name: "test", URL: "file://" + repoDir, Commit: base.String()},
+		Project:  CommandData{Name: "my-project"},
+		Answers:  map[string]interface{}{"removed": "old"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the question of the base version is removed and a required question is added
+	commitTestFile(t, repo, repoDir, testSurveyFile, "questions:\n  - name: added\n    type: input\n    message: Added\n    required: true\n")
+	commitTestFile(t, repo, repoDir, "a.txt", "butler{ getAdded }\n")
+
+	command := New(
+		SetConfigName(testSurveyFile),
+		WithAutoConfirm(true),
+		WithCommandData(&CommandData{Path: projectDir}),
+		WithTemplateSurveyResults(map[string]interface{}{"added": "new"}),
+	)
+	summary, err := command.Update(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Changed) != 1 || summary.Changed[0] != "a.txt" {
+		t.Errorf("expected a.txt to be changed, got %+v", summary)
+	}
+	dat, err := ioutil.ReadFile(filepath.Join(projectDir, "a.txt"))
+	if err != nil || string(dat) != "new\n" {
+		t.Errorf("expected the new answer to be rendered, got %q %v", dat, err)
+	}
+
+	manifest, err := ReadManifest(projectDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := manifest.Answers["removed"]; ok || manifest.Answers["added"] != "new" {
+		t.Errorf("expected only the answers of the new survey, got %v", manifest.Answers)
+	}
+}
+
+func TestMergeProjectBinary(t *testing.T) {
+	dir := testTempDir(t)
+	defer os.RemoveAll(dir)
+
+	oldDir, newDir, projectDir := filepath.Join(dir, "old"), filepath.Join(dir, "new"), filepath.Join(dir, "project")
+	writeTestFile(t, filepath.Join(oldDir, "logo.png"), "old\x00", 0644)
+	writeTestFile(t, filepath.Join(newDir, "logo.png"), "template\x00", 0644)
+	writeTestFile(t, filepath.Join(projectDir, "logo.png"), "project\x00", 0644)
+
+	summary, err := mergeProject(oldDir, newDir, projectDir, "abc", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Conflicted) != 1 || summary.Conflicted[0] != "logo.png" {
+		t.Errorf("expected logo.png to be conflicted, got %+v", summary)
+	}
+
+	dat, _ := ioutil.ReadFile(filepath.Join(projectDir, "logo.png"))
+	if string(dat) != "project\x00" {
+		t.Errorf("expected the project file to be kept, got %q", dat)
+	}
+	dat, _ = ioutil.ReadFile(filepath.Join(projectDir, "logo.png"+newFileSuffix))
+	if string(dat) != "template\x00" {
+		t.Errorf("expected the template version next to the file, got %q", dat)
+	}
+}
+
+func TestNewManifestTimes(t *testing.T) {
+	command := New(WithNow(oldTime), WithCommandData(&CommandData{Name: "my-project"}))
+	manifest := command.newManifest(&config.Template{Name: "test"})
+
+	if manifest.CreatedAt.Equal(oldTime) || time.Since(manifest.CreatedAt) > time.Minute {
+		t.Errorf("expected the real creation time, got %v", manifest.CreatedAt)
+	}
+	if !manifest.Render.Now.Equal(oldTime) {
+		t.Errorf("expected the fixed time %v to be recorded, got %v", oldTime, manifest.Render.Now)
+	}
+}
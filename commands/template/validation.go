@@ -0,0 +1,173 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	survey "gopkg.in/AlecAivazis/survey.v1"
+)
+
+// AnswerValidator evaluates the validate expression of the question with the answer
+// and the answers given so far
+type AnswerValidator func(question Question, answer interface{}, answers map[string]interface{}) (bool, error)
+
+// hasRules reports whether the question has any validation rule
+func (q Question) hasRules() bool {
+	return q.hasDeclarativeRules() || q.Validate != ""
+}
+
+// hasDeclarativeRules reports whether the question has any rule besides the validate expression
+func (q Question) hasDeclarativeRules() bool {
+	return q.Pattern != "" || q.MinLength != nil || q.MaxLength != nil ||
+		q.Min != nil || q.Max != nil || len(q.OneOf) > 0 || q.MustExist || q.Directory
+}
+
+// questionValidator returns the survey validator for the required flag and the rules of the question
+func questionValidator(question Question, answers map[string]interface{}, valid AnswerValidator) survey.Validator {
+	return func(val interface{}) error {
+		if question.Required {
+			if err := survey.Required(val); err != nil {
+				return err
+			}
+		}
+		answer, err := normalizeAnswer(question, val)
+		if err != nil {
+			return err
+		}
+		return validateRules(question, answer, answers, valid)
+	}
+}
+
+// validateRules checks the normalized answer against the validation rules of the question.
+// Empty texts of optional questions are always valid.
+func validateRules(question Question, answer interface{}, answers map[string]interface{}, valid AnswerValidator) error {
+	if !question.hasRules() {
+		return nil
+	}
+	if text, ok := answer.(string); ok && !question.Required && strings.TrimSpace(text) == "" {
+		return nil
+	}
+
+	values := []string{}
+	switch v := answer.(type) {
+	case string:
+		values = append(values, v)
+	case int:
+		values = append(values, strconv.Itoa(v))
+	case float64:
+		values = append(values, formatNumber(v))
+	case []string:
+		values = v
+		unit := "entries"
+		if question.Type == "multiselect" {
+			unit = "options"
+		}
+		if err := validateLength(question, len(v), unit); err != nil {
+			return err
+		}
+	// the rules are applied to the keys
+	case map[string]string:
+		for k := range v {
+			values = append(values, k)
+		}
+		sort.Strings(values)
+		if err := validateLength(question, len(v), "entries"); err != nil {
+			return err
+		}
+	}
+
+	if question.Type == "path" {
+		if err := validatePath(question, answer.(string)); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range values {
+		if _, ok := answer.(string); ok {
+			if err := validateLength(question, utf8.RuneCountInString(v), "characters"); err != nil {
+				return err
+			}
+		}
+
+		if question.Pattern != "" {
+			matched, err := regexp.MatchString(question.Pattern, v)
+			if err != nil {
+				return errors.Wrap(err, "invalid pattern")
+			}
+			if !matched {
+				return fmt.Errorf("'%s' must match the pattern '%s'", v, question.Pattern)
+			}
+		}
+
+		if len(question.OneOf) > 0 && !containsString(question.OneOf, v) {
+			return fmt.Errorf("'%s' must be one of [%s]", v, strings.Join(question.OneOf, ", "))
+		}
+
+		if question.Min != nil || question.Max != nil {
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return fmt.Errorf("'%s' must be a number", v)
+			}
+			if question.Min != nil && n < *question.Min {
+				return fmt.Errorf("%s must be at least %s", v, formatNumber(*question.Min))
+			}
+			if question.Max != nil && n > *question.Max {
+				return fmt.Errorf("%s must be at most %s", v, formatNumber(*question.Max))
+			}
+		}
+	}
+
+	if question.Validate != "" && valid != nil {
+		ok, err := valid(question, answer, answers)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if question.ValidateMessage != "" {
+				return errors.New(question.ValidateMessage)
+			}
+			return fmt.Errorf("'%v' is invalid", answer)
+		}
+	}
+
+	return nil
+}
+
+// validatePath checks the existence and type of the path
+func validatePath(question Question, path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if question.MustExist {
+			return fmt.Errorf("'%s' doesn't exist", path)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if question.Directory && !info.IsDir() {
+		return fmt.Errorf("'%s' isn't a directory", path)
+	}
+	return nil
+}
+
+// validateLength checks the length of a text or the number of selected options
+func validateLength(question Question, length int, unit string) error {
+	if question.MinLength != nil && length < *question.MinLength {
+		return fmt.Errorf("at least %d %s are required", *question.MinLength, unit)
+	}
+	if question.MaxLength != nil && length > *question.MaxLength {
+		return fmt.Errorf("at most %d %s are allowed", *question.MaxLength, unit)
+	}
+	return nil
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
@@ -17,8 +17,24 @@ type (
 	// Template represents the project template with informations about location
 	// and name
 	Template struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
+		Name     string   `json:"name"`
+		URL      string   `json:"url"`
+		Ref      string   `json:"ref"`
+		Path     string   `json:"path"`
+		Checksum string   `json:"checksum"`
+		Auth     *GitAuth `json:"auth"`
+	}
+	// GitAuth represents the credentials to clone private template repositories.
+	// Secrets are never stored in the config, they are read from environment variables.
+	GitAuth struct {
+		SSHKey           string `json:"sshKey" yaml:"sshKey"`
+		SSHKeyPassEnv    string `json:"sshKeyPassEnv" yaml:"sshKeyPassEnv"`
+		SSHAgent         bool   `json:"sshAgent" yaml:"sshAgent"`
+		KnownHosts       string `json:"knownHosts" yaml:"knownHosts"`
+		Username         string `json:"username" yaml:"username"`
+		UsernameEnv      string `json:"usernameEnv" yaml:"usernameEnv"`
+		PasswordEnv      string `json:"passwordEnv" yaml:"passwordEnv"`
+		CredentialHelper bool   `json:"credentialHelper" yaml:"credentialHelper"`
 	}
 	ConfluencePage struct {
 		Name     string           `json:"name"`
@@ -35,6 +51,9 @@ type (
 	Config struct {
 		Templates            []Template             `json:"templates"`
 		Variables            map[string]interface{} `json:"variables"`
+		GitAuth              *GitAuth               `json:"gitAuth" yaml:"gitAuth" ignored:"true"`
+		CacheDir             string                 `json:"cacheDir" yaml:"cacheDir" split_words:"true"`
+		Offline              bool                   `json:"offline" yaml:"offline"`
 		ConfigURL            string                 `split_words:"true"`
 		ConfluenceURL        string                 `split_words:"true"`
 		ConfluenceAuthMethod string                 `split_words:"true"`
@@ -173,12 +192,24 @@ func mergeConfigs(a, b *Config) *Config {
 		a.Variables[k] = v
 	}
 
+	if b.GitAuth != nil {
+		a.GitAuth = b.GitAuth
+	}
+
+	if b.CacheDir != "" {
+		a.CacheDir = b.CacheDir
+	}
+
+	if b.Offline {
+		a.Offline = true
+	}
+
 	// merge templates
 	for _, v := range b.Templates {
 		found := false
 		for j, v2 := range a.Templates {
 			if v.Name == v2.Name {
-				a.Templates[j] = v2
+				a.Templates[j] = v
 				found = true
 				break
 			}
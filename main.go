@@ -6,6 +6,8 @@ import (
 	"os"
 	"runtime"
 	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/skratchdot/open-golang/open"
 
@@ -156,6 +158,8 @@ func interactiveCliMode() {
 			template.WithVariables(cfg.Variables),
 			template.SetConfigName(surveyFilename),
 			template.WithButlerVersion(version),
+			template.WithGitAuth(cfg.GitAuth),
+			template.WithCache(templateCache()),
 			template.WithCwd(cd),
 		)
 
@@ -284,6 +288,379 @@ func dumpConfig() error {
 	return nil
 }
 
+func createProject(c *cli.Context) error {
+	if c.String("template") == "" {
+		return fmt.Errorf("missing required flag --template")
+	}
+
+	reproducible, err := reproducibleOptions(c)
+	if err != nil {
+		return err
+	}
+
+	cd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	answers := map[string]interface{}{}
+	if c.String("answers") != "" {
+		answers, err = template.ReadAnswersFile(c.String("answers"))
+		if err != nil {
+			return err
+		}
+	}
+
+	options := []template.Option{
+		template.WithTemplates(cfg.Templates),
+		template.WithVariables(cfg.Variables),
+		template.SetConfigName(surveyFilename),
+		template.WithButlerVersion(version),
+		template.WithGitAuth(cfg.GitAuth),
+		template.WithCache(templateCache()),
+		template.WithCwd(cd),
+		template.WithInteractive(false),
+		template.WithAutoConfirm(c.Bool("yes")),
+		template.WithDryRun(c.Bool("dry-run")),
+		template.WithConflictPolicy(c.String("conflict")),
+		template.WithRollback(!c.Bool("no-rollback")),
+		template.WithPreserveTimes(c.Bool("preserve-mtime")),
+		template.WithStrict(c.Bool("strict")),
+		template.WithErrorReport(c.String("error-report")),
+		template.WithCommandData(&template.CommandData{
+			Template:    c.String("template"),
+			Name:        c.String("name"),
+			Description: c.String("description"),
+			Path:        c.String("path"),
+		}),
+		template.WithTemplateSurveyResults(answers),
+	}
+	command := template.New(append(options, reproducible...)...)
+
+	err = command.Run()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("dry-run") {
+		return nil
+	}
+
+	fmt.Println()
+	command.TaskTracker.PrintSummary(os.Stdout)
+
+	return nil
+}
+
+func updateProject(c *cli.Context) error {
+	cd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	projectDir := c.String("path")
+	if projectDir == "" {
+		projectDir = cd
+	}
+
+	answers := map[string]interface{}{}
+	if c.String("answers") != "" {
+		answers, err = template.ReadAnswersFile(c.String("answers"))
+		if err != nil {
+			return err
+		}
+	}
+
+	command := template.New(
+		template.WithTemplates(cfg.Templates),
+		template.WithVariables(cfg.Variables),
+		template.SetConfigName(surveyFilename),
+		template.WithButlerVersion(version),
+		template.WithGitAuth(cfg.GitAuth),
+		template.WithCache(templateCache()),
+		template.WithCwd(cd),
+		template.WithAutoConfirm(c.Bool("yes")),
+		template.WithCommandData(&template.CommandData{
+			Path: projectDir,
+		}),
+		template.WithTemplateSurveyResults(answers),
+	)
+
+	summary, err := command.Update(c.Bool("reject"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	summary.PrintSummary(os.Stdout)
+
+	return nil
+}
+
+func generate(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return fmt.Errorf("missing generator name")
+	}
+
+	reproducible, err := reproducibleOptions(c)
+	if err != nil {
+		return err
+	}
+
+	cd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	projectDir := c.String("path")
+	if projectDir == "" {
+		projectDir = cd
+	}
+
+	answers := map[string]interface{}{}
+	if c.String("answers") != "" {
+		answers, err = template.ReadAnswersFile(c.String("answers"))
+		if err != nil {
+			return err
+		}
+	}
+
+	options := []template.Option{
+		template.WithTemplates(cfg.Templates),
+		template.WithVariables(cfg.Variables),
+		template.SetConfigName(surveyFilename),
+		template.WithButlerVersion(version),
+		template.WithGitAuth(cfg.GitAuth),
+		template.WithCache(templateCache()),
+		template.WithCwd(cd),
+		template.WithInteractive(c.String("answers") == ""),
+		template.WithAutoConfirm(c.Bool("yes")),
+		template.WithDryRun(c.Bool("dry-run")),
+		template.WithConflictPolicy(c.String("conflict")),
+		template.WithRollback(!c.Bool("no-rollback")),
+		template.WithPreserveTimes(c.Bool("preserve-mtime")),
+		template.WithErrorReport(c.String("error-report")),
+		template.WithCommandData(&template.CommandData{
+			Path: projectDir,
+		}),
+		template.WithTemplateSurveyResults(answers),
+	}
+	command := template.New(append(options, reproducible...)...)
+
+	summary, err := command.Generate(name)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("dry-run") {
+		return nil
+	}
+
+	fmt.Println()
+	summary.PrintSummary(os.Stdout)
+
+	return nil
+}
+
+func testTemplate(c *cli.Context) error {
+	cd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	dir := c.Args().First()
+	if dir == "" {
+		dir = cd
+	}
+
+	command := template.New(
+		template.SetConfigName(surveyFilename),
+		template.WithButlerVersion(version),
+		template.WithCwd(cd),
+		template.WithInteractive(false),
+	)
+
+	report, err := command.Test(dir, c.Bool("update"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	report.Print(os.Stdout)
+
+	if failed := report.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d test cases failed", failed, len(report.Results))
+	}
+
+	return nil
+}
+
+func lintTemplate(c *cli.Context) error {
+	cd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	dir := c.Args().First()
+	if dir == "" {
+		dir = cd
+	}
+
+	command := template.New(
+		template.WithVariables(cfg.Variables),
+		template.SetConfigName(surveyFilename),
+		template.WithButlerVersion(version),
+		template.WithCwd(cd),
+		template.WithInteractive(false),
+	)
+
+	report, err := command.Lint(dir)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		err = report.PrintJSON(os.Stdout)
+		if err != nil {
+			return err
+		}
+	} else {
+		report.Print(os.Stdout)
+	}
+
+	if n := report.Errors(); n > 0 {
+		return fmt.Errorf("template contains %d errors", n)
+	}
+
+	return nil
+}
+
+// reproducibleOptions returns the options for the seed and the time of the flags
+func reproducibleOptions(c *cli.Context) ([]template.Option, error) {
+	options := []template.Option{}
+	if c.IsSet("seed") {
+		options = append(options, template.WithSeed(c.Int64("seed")))
+	}
+	if c.String("now") != "" {
+		now, err := template.ParseTime(c.String("now"))
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, template.WithNow(now))
+	}
+	return options, nil
+}
+
+// templateCache returns the cache for remote templates
+func templateCache() *template.Cache {
+	dir := cfg.CacheDir
+	if dir == "" {
+		var err error
+		dir, err = template.DefaultCacheDir()
+		if err != nil {
+			logy.WithError(err).Warn("template cache is disabled")
+			return nil
+		}
+	}
+
+	return template.NewCache(dir, cfg.Offline)
+}
+
+// configuredTemplate returns the configured template with the url
+func configuredTemplate(url string) *config.Template {
+	for i, tpl := range cfg.Templates {
+		if tpl.URL == url {
+			return &cfg.Templates[i]
+		}
+	}
+	return nil
+}
+
+func listCache(c *cli.Context) error {
+	cache := templateCache()
+	if cache == nil {
+		return fmt.Errorf("template cache is disabled")
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		return err
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "URL\tUpdated\tKey\t")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", entry.URL, entry.UpdatedAt.Format("2006-01-02 15:04:05"), entry.Key)
+	}
+
+	return w.Flush()
+}
+
+func pruneCache(c *cli.Context) error {
+	cache := templateCache()
+	if cache == nil {
+		return fmt.Errorf("template cache is disabled")
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		// keep the templates of the config
+		if !c.Bool("all") && configuredTemplate(entry.URL) != nil {
+			continue
+		}
+		err = cache.Remove(entry)
+		if err != nil {
+			return err
+		}
+		logy.Infof("removed '%s' from cache", entry.URL)
+	}
+
+	return nil
+}
+
+func refreshCache(c *cli.Context) error {
+	if cfg.Offline {
+		return fmt.Errorf("the cache can't be refreshed in offline mode")
+	}
+
+	cache := templateCache()
+	if cache == nil {
+		return fmt.Errorf("template cache is disabled")
+	}
+
+	entries, err := cache.Entries()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, entry := range entries {
+		var auth *config.GitAuth
+		if tpl := configuredTemplate(entry.URL); tpl != nil {
+			auth = tpl.Auth
+		}
+		err = cache.Refresh(entry, auth, cfg.GitAuth)
+		if err != nil {
+			logy.WithError(err).Errorf("could not refresh '%s'", entry.URL)
+			failed++
+			continue
+		}
+		logy.Infof("refreshed '%s'", entry.URL)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d templates could not be refreshed", failed)
+	}
+
+	return nil
+}
+
 func cliMode() {
 	type surveyResult map[string]interface{}
 
@@ -301,6 +678,17 @@ func cliMode() {
 			Usage:  "Log level",
 			EnvVar: "BUTLER_LOG_LEVEL",
 		},
+		cli.BoolFlag{
+			Name:  "offline",
+			Usage: "Use the cached templates without network access",
+		},
+	}
+
+	app.Before = func(c *cli.Context) error {
+		if c.GlobalBool("offline") {
+			cfg.Offline = true
+		}
+		return nil
 	}
 
 	app.Commands = []cli.Command{
@@ -314,6 +702,221 @@ func cliMode() {
 				return nil
 			},
 		},
+		{
+			Name:  "create",
+			Usage: "Create a new project without prompts",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "template, t",
+					Usage: "The name of the template",
+				},
+				cli.StringFlag{
+					Name:  "name, n",
+					Usage: "The project name",
+				},
+				cli.StringFlag{
+					Name:  "description, d",
+					Usage: "The project description",
+				},
+				cli.StringFlag{
+					Name:  "path, p",
+					Usage: "The destination of the project (default: current directory)",
+				},
+				cli.StringFlag{
+					Name:  "answers, a",
+					Usage: "Path to a yaml or json file with the survey answers",
+				},
+				cli.BoolFlag{
+					Name:  "yes, y",
+					Usage: "Checkout the project without confirmation",
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Print the resulting project tree without checkout",
+				},
+				cli.StringFlag{
+					Name:  "conflict",
+					Usage: "Policy for existing files in the destination: " + strings.Join(template.ConflictPolicies, ", ") + " (default: abort)",
+				},
+				cli.BoolFlag{
+					Name:  "no-rollback",
+					Usage: "Keep the project when a required after hook fails",
+				},
+				cli.BoolFlag{
+					Name:  "preserve-mtime",
+					Usage: "Keep the modification times of the template files",
+				},
+				cli.Int64Flag{
+					Name:  "seed",
+					Usage: "Seed of the random functions for a reproducible project, it's recorded in the manifest",
+				},
+				cli.StringFlag{
+					Name:  "now",
+					Usage: "Fixed time of the dates as RFC3339 or YYYY-MM-DD, it's recorded in the manifest",
+				},
+				cli.BoolFlag{
+					Name:  "strict",
+					Usage: "Abort without checkout when a file of the template can't be rendered",
+				},
+				cli.StringFlag{
+					Name:  "error-report",
+					Usage: "Write the template errors as json to the file",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				setLogLevel(c.GlobalString("logLevel"))
+				return createProject(c)
+			},
+		},
+		{
+			Name:  "update",
+			Usage: "Apply the latest template version onto an existing project",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "path, p",
+					Usage: "The project directory with a butler manifest (default: current directory)",
+				},
+				cli.StringFlag{
+					Name:  "answers, a",
+					Usage: "Path to a yaml or json file with additional survey answers",
+				},
+				cli.BoolFlag{
+					Name:  "reject",
+					Usage: "Write conflicts to .rej files instead of conflict markers",
+				},
+				cli.BoolFlag{
+					Name:  "yes, y",
+					Usage: "Update the project without confirmation",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				setLogLevel(c.GlobalString("logLevel"))
+				return updateProject(c)
+			},
+		},
+		{
+			Name:      "generate",
+			Usage:     "Add the files of a template generator to an existing project",
+			ArgsUsage: "<name>",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "path, p",
+					Usage: "The project directory with a butler manifest (default: current directory)",
+				},
+				cli.StringFlag{
+					Name:  "answers, a",
+					Usage: "Path to a yaml or json file with the survey answers, the survey is skipped",
+				},
+				cli.BoolFlag{
+					Name:  "yes, y",
+					Usage: "Add the files without confirmation",
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Print the files of the generator without writing them",
+				},
+				cli.StringFlag{
+					Name:  "conflict",
+					Usage: "Policy for existing files in the project: " + strings.Join(template.ConflictPolicies, ", ") + " (default: prompt without --answers, otherwise abort)",
+				},
+				cli.BoolFlag{
+					Name:  "no-rollback",
+					Usage: "Keep the added files when a required after hook fails",
+				},
+				cli.BoolFlag{
+					Name:  "preserve-mtime",
+					Usage: "Keep the modification times of the generator files",
+				},
+				cli.Int64Flag{
+					Name:  "seed",
+					Usage: "Seed of the random functions for a reproducible generator, it's recorded in the manifest",
+				},
+				cli.StringFlag{
+					Name:  "now",
+					Usage: "Fixed time of the dates as RFC3339 or YYYY-MM-DD, it's recorded in the manifest",
+				},
+				cli.StringFlag{
+					Name:  "error-report",
+					Usage: "Write the template errors as json to the file",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				setLogLevel(c.GlobalString("logLevel"))
+				return generate(c)
+			},
+		},
+		{
+			Name:  "template",
+			Usage: "Tools for template authors",
+			Subcommands: []cli.Command{
+				{
+					Name:      "test",
+					Usage:     "Render the test cases of a template and compare them with the expected files",
+					ArgsUsage: "[template directory]",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "update",
+							Usage: "Replace the golden directories with the rendered projects",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						setLogLevel(c.GlobalString("logLevel"))
+						return testTemplate(c)
+					},
+				},
+				{
+					Name:      "lint",
+					Usage:     "Check the survey and the files of a template without rendering it",
+					ArgsUsage: "[template directory]",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "json",
+							Usage: "Print the issues as json",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						setLogLevel(c.GlobalString("logLevel"))
+						return lintTemplate(c)
+					},
+				},
+			},
+		},
+		{
+			Name:  "cache",
+			Usage: "Manage the cache of remote templates",
+			Subcommands: []cli.Command{
+				{
+					Name:  "list",
+					Usage: "List all cached templates",
+					Action: func(c *cli.Context) error {
+						setLogLevel(c.GlobalString("logLevel"))
+						return listCache(c)
+					},
+				},
+				{
+					Name:  "prune",
+					Usage: "Remove cached templates which aren't configured anymore",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "all",
+							Usage: "Remove all cached templates",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						setLogLevel(c.GlobalString("logLevel"))
+						return pruneCache(c)
+					},
+				},
+				{
+					Name:  "refresh",
+					Usage: "Fetch the latest changes of all cached templates",
+					Action: func(c *cli.Context) error {
+						setLogLevel(c.GlobalString("logLevel"))
+						return refreshCache(c)
+					},
+				},
+			},
+		},
 		{
 			Name:   "dump-config",
 			Usage:  "Dumps the final config file",
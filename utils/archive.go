@@ -0,0 +1,273 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// MaxArchiveSize limits the uncompressed size of an archive to protect against zip bombs
+	MaxArchiveSize = 1 << 30
+	// MaxArchiveEntries limits the number of files and directories of an archive
+	MaxArchiveEntries = 100000
+)
+
+// ExtractArchive extracts a "zip", "tar" or "tar.gz" archive into the directory dst.
+// Entries which would be written outside of dst and symlinks which point outside of
+// dst are rejected. Symlinks are created after all files and directories so nothing
+// is written through them. Hardlinks are ignored and skipped. The modification times
+// of the files are kept.
+func ExtractArchive(src, dst, format string) error {
+	switch format {
+	case "zip":
+		return extractZip(src, dst)
+	case "tar", "tar.gz":
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		var r io.Reader = f
+		if format == "tar.gz" {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return err
+			}
+			defer gz.Close()
+			r = gz
+		}
+
+		return extractTar(r, dst)
+	}
+
+	return fmt.Errorf("unsupported archive format '%s'", format)
+}
+
+func extractZip(src, dst string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if len(r.File) > MaxArchiveEntries {
+		return fmt.Errorf("archive contains more than %d entries", MaxArchiveEntries)
+	}
+
+	var written int64
+	links := map[string]string{}
+	for _, f := range r.File {
+		target, err := archivePath(dst, f.Name)
+		if err != nil {
+			return err
+		}
+
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = os.MkdirAll(target, mode.Perm()|0700)
+			if err != nil {
+				return err
+			}
+		case mode&os.ModeSymlink != 0:
+			// the target of the link is the content of the entry
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			link, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			links[target] = string(link)
+		case mode.IsRegular():
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			n, err := writeArchiveFile(target, rc, mode.Perm(), MaxArchiveSize-written)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+			written += n
+			err = os.Chtimes(target, f.Modified, f.Modified)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return writeArchiveSymlinks(dst, links)
+}
+
+func extractTar(r io.Reader, dst string) error {
+	tr := tar.NewReader(r)
+
+	var written int64
+	links := map[string]string{}
+	for entries := 0; ; entries++ {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return writeArchiveSymlinks(dst, links)
+		}
+		if err != nil {
+			return err
+		}
+
+		if entries >= MaxArchiveEntries {
+			return fmt.Errorf("archive contains more than %d entries", MaxArchiveEntries)
+		}
+
+		target, err := archivePath(dst, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, os.FileMode(header.Mode).Perm()|0700)
+			if err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			links[target] = header.Linkname
+		case tar.TypeReg, tar.TypeRegA:
+			n, err := writeArchiveFile(target, tr, os.FileMode(header.Mode).Perm(), MaxArchiveSize-written)
+			if err != nil {
+				return err
+			}
+			written += n
+			err = os.Chtimes(target, header.ModTime, header.ModTime)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// archivePath returns the destination of the archive entry and ensures that it doesn't
+// escape the directory
+func archivePath(dst, name string) (string, error) {
+	dst = filepath.Clean(dst)
+	target := filepath.Join(dst, filepath.FromSlash(name))
+	if target != dst && !strings.HasPrefix(target, dst+string(filepath.Separator)) {
+		return "", fmt.Errorf("illegal path '%s' in archive", name)
+	}
+	return target, checkArchiveParents(dst, target)
+}
+
+// checkArchiveParents ensures that no directory between dst and the target is a
+// symlink so the entry isn't written through a symlink of the archive
+func checkArchiveParents(dst, target string) error {
+	dst = filepath.Clean(dst)
+	for dir := filepath.Dir(target); dir != dst && strings.HasPrefix(dir, dst); dir = filepath.Dir(dir) {
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("illegal path '%s' through symlink in archive", target[len(dst)+1:])
+		}
+	}
+	return nil
+}
+
+// writeArchiveSymlinks creates the symlinks sorted by their paths. All symlinks are
+// checked again at the end because a symlink can change the target of another one.
+func writeArchiveSymlinks(dst string, links map[string]string) error {
+	targets := make([]string, 0, len(links))
+	for target := range links {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	for _, target := range targets {
+		err := writeArchiveSymlink(dst, target, links[target])
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, target := range targets {
+		inside, err := LinkInside(dst, target)
+		if err != nil {
+			return err
+		}
+		if !inside {
+			os.Remove(target)
+			return fmt.Errorf("illegal symlink '%s' to '%s' in archive", target[len(filepath.Clean(dst))+1:], links[target])
+		}
+	}
+
+	return nil
+}
+
+// writeArchiveSymlink creates the symlink and removes it again when it points outside of dst
+func writeArchiveSymlink(dst, target, link string) error {
+	err := checkArchiveParents(dst, target)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = os.Symlink(link, target)
+	if err != nil {
+		return err
+	}
+
+	inside, err := LinkInside(dst, target)
+	if err != nil || !inside {
+		os.Remove(target)
+	}
+	if err != nil {
+		return err
+	}
+	if !inside {
+		return fmt.Errorf("illegal symlink '%s' to '%s' in archive", target[len(filepath.Clean(dst))+1:], link)
+	}
+
+	return nil
+}
+
+// writeArchiveFile writes the content of the reader into the file. The size of the
+// content is limited to protect against decompression bombs.
+func writeArchiveFile(target string, r io.Reader, perm os.FileMode, limit int64) (int64, error) {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm|0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if err != nil {
+		return n, err
+	}
+	if n > limit {
+		return n, fmt.Errorf("archive exceeds the maximum size of %d bytes", int64(MaxArchiveSize))
+	}
+
+	return n, nil
+}
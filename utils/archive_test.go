@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteArchiveSymlink(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		link string
+		err  string
+	}{
+		{"relative", "link", "file", ""},
+		{"nested", "sub/link", "../file", ""},
+		{"dangling inside", "link", "missing", ""},
+		{"absolute", "link", "/etc/passwd", "illegal symlink 'link' to '/etc/passwd'"},
+		{"parent", "link", "../file", "illegal symlink 'link' to '../file'"},
+		{"nested parent", "sub/link", "../../file", "illegal symlink 'sub/link' to '../../file'"},
+		{"dangling parent", "link", "../missing", "illegal symlink 'link' to '../missing'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+
+			dst := filepath.Join(dir, "dst")
+			writeFile(t, filepath.Join(dst, "file"), "", 0644)
+			writeFile(t, filepath.Join(dir, "file"), "", 0644)
+			target := filepath.Join(dst, filepath.FromSlash(tt.path))
+
+			err := writeArchiveSymlink(dst, target, tt.link)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if link, _ := os.Readlink(target); link != tt.link {
+					t.Errorf("expected link to '%s', got '%s'", tt.link, link)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error containing %q, got %v", tt.err, err)
+			}
+			if _, err := os.Lstat(target); !os.IsNotExist(err) {
+				t.Errorf("expected the illegal symlink to be removed")
+			}
+		})
+	}
+}
+
+// archiveEntry is an entry of a test archive
+type archiveEntry struct {
+	name string
+	mode os.FileMode
+	body string
+	link string
+}
+
+// symlinkChain escapes with a symlink whose target changes when a later symlink is created
+var symlinkChain = []archiveEntry{
+	{name: "a", mode: os.ModeSymlink | 0777, link: "c/.."},
+	{name: "c", mode: os.ModeSymlink | 0777, link: "."},
+	{name: "a/evil", mode: 0644, body: "evil"},
+}
+
+var validArchive = []archiveEntry{
+	{name: "gradlew", mode: 0755, body: "#!/bin/sh"},
+	{name: "docs/", mode: os.ModeDir | 0755},
+	{name: "docs/readme.md", mode: 0644, body: "docs"},
+	{name: "empty/", mode: os.ModeDir | 0755},
+	{name: "readme.md", mode: os.ModeSymlink | 0777, link: "docs/readme.md"},
+}
+
+func TestExtractArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		entries []archiveEntry
+		err     string
+	}{
+		{"zip", "zip", validArchive, ""},
+		{"tar", "tar", validArchive, ""},
+		{"tar.gz", "tar.gz", validArchive, ""},
+		{"zip slip", "zip", []archiveEntry{{name: "../evil", mode: 0644}}, "illegal path '../evil'"},
+		{"tar slip", "tar", []archiveEntry{{name: "../evil", mode: 0644}}, "illegal path '../evil'"},
+		{"zip absolute symlink", "zip", []archiveEntry{{name: "link", mode: os.ModeSymlink | 0777, link: "/etc"}}, "illegal symlink"},
+		{"tar escaping symlink", "tar", []archiveEntry{{name: "link", mode: os.ModeSymlink | 0777, link: "../.."}}, "illegal symlink"},
+		{"tar symlink chain", "tar", symlinkChain[:2], "illegal symlink 'a' to 'c/..'"},
+		{"zip symlink chain", "zip", symlinkChain[:2], "illegal symlink 'a' to 'c/..'"},
+		{"tar write through symlink chain", "tar", symlinkChain, "file exists"},
+		{"zip write through symlink chain", "zip", symlinkChain, "file exists"},
+		{"tar symlink through symlink", "tar", []archiveEntry{{name: "a", mode: os.ModeSymlink | 0777, link: "b"}, {name: "b/", mode: os.ModeDir | 0755}, {name: "a/link", mode: os.ModeSymlink | 0777, link: "x"}}, "through symlink"},
+		{"unknown format", "rar", nil, "unsupported archive format"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+
+			src := filepath.Join(dir, "archive")
+			writeTestArchive(t, src, tt.format, tt.entries)
+			dst := filepath.Join(dir, "dst")
+
+			err := ExtractArchive(src, dst, tt.format)
+			if _, statErr := os.Lstat(filepath.Join(dir, "evil")); statErr == nil {
+				t.Fatal("expected no file outside of the destination")
+			}
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(filepath.Join(dst, "gradlew"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0755 {
+				t.Errorf("expected the executable bit to be kept, got %v", info.Mode().Perm())
+			}
+			assertModTime(t, info, true)
+			if info, err := os.Stat(filepath.Join(dst, "empty")); err != nil || !info.IsDir() {
+				t.Errorf("expected the empty directory to be kept: %v", err)
+			}
+			if link, _ := os.Readlink(filepath.Join(dst, "readme.md")); link != "docs/readme.md" {
+				t.Errorf("expected the symlink to be kept, got '%s'", link)
+			}
+		})
+	}
+}
+
+// writeTestArchive writes the entries as archive of the format
+func writeTestArchive(t *testing.T, path, format string, entries []archiveEntry) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	switch format {
+	case "zip":
+		zw := zip.NewWriter(f)
+		for _, e := range entries {
+			header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+			header.SetMode(e.mode)
+			header.Modified = oldTime
+			w, err := zw.CreateHeader(header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := e.body
+			if e.link != "" {
+				body = e.link
+			}
+			if _, err := w.Write([]byte(body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	case "tar", "tar.gz":
+		var gz *gzip.Writer
+		tw := tar.NewWriter(f)
+		if format == "tar.gz" {
+			gz = gzip.NewWriter(f)
+			tw = tar.NewWriter(gz)
+		}
+		for _, e := range entries {
+			header := &tar.Header{Name: e.name, Mode: int64(e.mode.Perm()), ModTime: oldTime, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+			switch {
+			case e.mode.IsDir():
+				header.Typeflag, header.Size = tar.TypeDir, 0
+			case e.mode&os.ModeSymlink != 0:
+				header.Typeflag, header.Size, header.Linkname = tar.TypeSymlink, 0, e.link
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				t.Fatal(err)
+			}
+			if header.Typeflag == tar.TypeReg {
+				if _, err := tw.Write([]byte(e.body)); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if gz != nil {
+			if err := gz.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	default:
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -6,14 +6,38 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// CopyOption configures the copy functions
+type CopyOption func(*copyOptions)
+
+type copyOptions struct {
+	preserveTimes bool
+}
+
+// WithPreserveTimes option.
+// The modification times of the copied files and directories are kept.
+func WithPreserveTimes(b bool) CopyOption {
+	return func(o *copyOptions) {
+		o.preserveTimes = b
+	}
+}
+
+func newCopyOptions(opts []CopyOption) *copyOptions {
+	o := &copyOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
 // CopyFile copies the contents of the file named src to the file named
 // by dst. The file will be created if it does not already exist. If the
 // destination file exists, all it's contents will be replaced by the contents
 // of the source file. The file mode will be copied from the source and
 // the copied data is synced/flushed to stable storage.
-func CopyFile(src, dst string) (err error) {
+func CopyFile(src, dst string, opts ...CopyOption) (err error) {
 	in, err := os.Open(src)
 	if err != nil {
 		return err
@@ -49,13 +73,106 @@ func CopyFile(src, dst string) (err error) {
 		return err
 	}
 
+	if newCopyOptions(opts).preserveTimes {
+		err = os.Chtimes(dst, si.ModTime(), si.ModTime())
+	}
+
 	return err
 }
 
+// CopySymlink recreates the symlink src at dst with the same target
+func CopySymlink(src, dst string) error {
+	link, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(link, dst)
+}
+
+// LinkInside reports whether the symlink at path resolves to a path inside of root.
+// Absolute targets are never inside. Dangling symlinks are resolved up to the first
+// missing file, the rest of the target is resolved lexically.
+func LinkInside(root, path string) (bool, error) {
+	link, err := os.Readlink(path)
+	if err != nil {
+		return false, err
+	}
+	if filepath.IsAbs(link) {
+		return false, nil
+	}
+
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return false, err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	target, err := resolvePath(path, 0)
+	if err != nil {
+		return false, err
+	}
+
+	return target == root || strings.HasPrefix(target, root+string(filepath.Separator)), nil
+}
+
+// maxSymlinks limits the symlinks which are followed to resolve a path
+const maxSymlinks = 255
+
+// resolvePath resolves all symlinks of the absolute path like filepath.EvalSymlinks.
+// The path doesn't have to exist, the components after the first missing one are
+// resolved lexically because they can't be followed. Unlike filepath.Join ".." isn't
+// applied before the symlink in front of it is resolved.
+func resolvePath(path string, links int) (string, error) {
+	parts := strings.Split(path, string(filepath.Separator))
+	cur := filepath.VolumeName(path) + string(filepath.Separator)
+	for i, part := range parts {
+		switch part {
+		case "", ".", filepath.VolumeName(path):
+			continue
+		case "..":
+			cur = filepath.Dir(cur)
+			continue
+		}
+
+		next := filepath.Join(cur, part)
+		info, err := os.Lstat(next)
+		if os.IsNotExist(err) {
+			return filepath.Join(append([]string{next}, parts[i+1:]...)...), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			cur = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many symlinks in '%s'", path)
+		}
+		link, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(link) {
+			link = cur + string(filepath.Separator) + link
+		}
+		cur, err = resolvePath(link, links)
+		if err != nil {
+			return "", err
+		}
+	}
+	return cur, nil
+}
+
 // CopyDir recursively copies a directory tree, attempting to preserve permissions.
 // Source directory must exist, destination directory must *not* exist.
-// Symlinks are ignored and skipped.
-func CopyDir(src string, dst string) (err error) {
+// Symlinks are recreated with the same target.
+func CopyDir(src string, dst string, opts ...CopyOption) (err error) {
 	src = filepath.Clean(src)
 	dst = filepath.Clean(dst)
 
@@ -75,7 +192,7 @@ func CopyDir(src string, dst string) (err error) {
 		return fmt.Errorf("destination already exists")
 	}
 
-	err = os.MkdirAll(dst, si.Mode())
+	err = os.MkdirAll(dst, si.Mode()|0700)
 	if err != nil {
 		return err
 	}
@@ -89,29 +206,39 @@ func CopyDir(src string, dst string) (err error) {
 		srcPath := filepath.Join(src, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
 
-		if entry.IsDir() {
-			err = CopyDir(srcPath, dstPath)
-			if err != nil {
-				return err
-			}
-		} else {
-			// Skip symlinks.
-			if entry.Mode()&os.ModeSymlink != 0 {
-				continue
-			}
-
-			err = CopyFile(srcPath, dstPath)
-			if err != nil {
-				return err
-			}
+		err = copyEntry(srcPath, dstPath, entry, opts)
+		if err != nil {
+			return err
 		}
 	}
 
+	// the mode is applied at last because read-only directories couldn't be filled
+	err = os.Chmod(dst, si.Mode())
+	if err != nil {
+		return err
+	}
+
+	// the directory is modified by its entries
+	if newCopyOptions(opts).preserveTimes {
+		err = os.Chtimes(dst, si.ModTime(), si.ModTime())
+	}
+
 	return err
 }
 
+// copyEntry copies a directory, symlink or file
+func copyEntry(src, dst string, info os.FileInfo, opts []CopyOption) error {
+	switch {
+	case info.IsDir():
+		return CopyDir(src, dst, opts...)
+	case info.Mode()&os.ModeSymlink != 0:
+		return CopySymlink(src, dst)
+	}
+	return CopyFile(src, dst, opts...)
+}
+
 // MoveDir copy and moves all files from src to dst
-func MoveDir(src string, dst string) (err error) {
+func MoveDir(src string, dst string, opts ...CopyOption) (err error) {
 	entries, err := ioutil.ReadDir(src)
 	if err != nil {
 		return err
@@ -121,21 +248,9 @@ func MoveDir(src string, dst string) (err error) {
 		srcPath := filepath.Join(src, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
 
-		if entry.IsDir() {
-			err = CopyDir(srcPath, dstPath)
-			if err != nil {
-				return err
-			}
-		} else {
-			// Skip symlinks.
-			if entry.Mode()&os.ModeSymlink != 0 {
-				continue
-			}
-
-			err = CopyFile(srcPath, dstPath)
-			if err != nil {
-				return err
-			}
+		err = copyEntry(srcPath, dstPath, entry, opts)
+		if err != nil {
+			return err
 		}
 	}
 
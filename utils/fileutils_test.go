@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// oldTime is the modification time of the source files
+var oldTime = time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestCopyFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          os.FileMode
+		preserveTimes bool
+	}{
+		{"regular", 0644, false},
+		{"executable", 0755, false},
+		{"private", 0600, false},
+		{"preserve times", 0755, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+
+			src := filepath.Join(dir, "src")
+			writeFile(t, src, "content", tt.mode)
+			chtimesTree(t, src)
+
+			dst := filepath.Join(dir, "dst")
+			err := CopyFile(src, dst, WithPreserveTimes(tt.preserveTimes))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := os.Stat(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != tt.mode {
+				t.Errorf("expected mode %v, got %v", tt.mode, info.Mode().Perm())
+			}
+			assertModTime(t, info, tt.preserveTimes)
+
+			dat, err := ioutil.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(dat) != "content" {
+				t.Errorf("expected content %q, got %q", "content", dat)
+			}
+		})
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	for _, preserveTimes := range []bool{false, true} {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		src := filepath.Join(dir, "src")
+		writeFile(t, filepath.Join(src, "gradlew"), "#!/bin/sh", 0755)
+		writeFile(t, filepath.Join(src, "git_hooks", "pre-commit"), "#!/bin/sh", 0755)
+		writeFile(t, filepath.Join(src, "docs", "readme.md"), "docs", 0644)
+		mkdir(t, filepath.Join(src, "empty"))
+		symlink(t, "docs/readme.md", filepath.Join(src, "readme.md"))
+		symlink(t, "../docs", filepath.Join(src, "git_hooks", "docs"))
+		chtimesTree(t, src)
+
+		dst := filepath.Join(dir, "dst")
+		err := CopyDir(src, dst, WithPreserveTimes(preserveTimes))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertTree(t, dst, preserveTimes)
+
+		err = CopyDir(src, dst)
+		if err == nil {
+			t.Errorf("expected an error when the destination exists")
+		}
+	}
+}
+
+func TestMoveDir(t *testing.T) {
+	for _, preserveTimes := range []bool{false, true} {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		src := filepath.Join(dir, "src")
+		writeFile(t, filepath.Join(src, "gradlew"), "#!/bin/sh", 0755)
+		writeFile(t, filepath.Join(src, "git_hooks", "pre-commit"), "#!/bin/sh", 0755)
+		writeFile(t, filepath.Join(src, "docs", "readme.md"), "docs", 0644)
+		mkdir(t, filepath.Join(src, "empty"))
+		symlink(t, "docs/readme.md", filepath.Join(src, "readme.md"))
+		symlink(t, "../docs", filepath.Join(src, "git_hooks", "docs"))
+		chtimesTree(t, src)
+
+		dst := filepath.Join(dir, "dst")
+		mkdir(t, dst)
+		err := MoveDir(src, dst, WithPreserveTimes(preserveTimes))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertTree(t, dst, preserveTimes)
+	}
+}
+
+func TestLinkInside(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		target string
+		inside bool
+	}{
+		{"relative file", "link", "file", true},
+		{"relative nested", "sub/link", "../file", true},
+		{"root", "sub/link", "..", true},
+		{"dangling inside", "link", "missing", true},
+		{"parent", "link", "../file", false},
+		{"nested parent", "sub/link", "../../file", false},
+		{"dangling parent", "link", "../missing", false},
+		{"absolute", "link", "/etc/passwd", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+
+			root := filepath.Join(dir, "root")
+			writeFile(t, filepath.Join(root, "file"), "", 0644)
+			writeFile(t, filepath.Join(dir, "file"), "", 0644)
+			mkdir(t, filepath.Join(root, "sub"))
+			path := filepath.Join(root, filepath.FromSlash(tt.path))
+			symlink(t, tt.target, path)
+
+			inside, err := LinkInside(root, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inside != tt.inside {
+				t.Errorf("expected inside %v for '%s' -> '%s', got %v", tt.inside, tt.path, tt.target, inside)
+			}
+		})
+	}
+}
+
+func TestLinkInsideChains(t *testing.T) {
+	tests := []struct {
+		name   string
+		links  [][2]string
+		inside bool
+	}{
+		{"parent of root link", [][2]string{{"c", "."}, {"a", "c/.."}}, false},
+		{"dangling through root link", [][2]string{{"c", "."}, {"a", "c/../missing"}}, false},
+		{"parent of nested link", [][2]string{{"c", "sub"}, {"a", "c/../file"}}, true},
+		{"chain", [][2]string{{"c", "sub"}, {"b", "c"}, {"a", "b/.."}}, true},
+		{"loop", [][2]string{{"c", "b"}, {"b", "c"}, {"a", "b/x"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tempDir(t)
+			defer os.RemoveAll(dir)
+
+			root := filepath.Join(dir, "root")
+			writeFile(t, filepath.Join(root, "file"), "", 0644)
+			mkdir(t, filepath.Join(root, "sub"))
+			for _, l := range tt.links {
+				symlink(t, l[1], filepath.Join(root, l[0]))
+			}
+
+			inside, err := LinkInside(root, filepath.Join(root, "a"))
+			if err != nil && tt.inside {
+				t.Fatal(err)
+			}
+			if inside != tt.inside {
+				t.Errorf("expected inside %v, got %v", tt.inside, inside)
+			}
+		})
+	}
+}
+
+// assertTree checks the copy of the test tree
+func assertTree(t *testing.T, dst string, preserveTimes bool) {
+	t.Helper()
+
+	for path, mode := range map[string]os.FileMode{
+		"gradlew":              0755,
+		"git_hooks/pre-commit": 0755,
+		"docs/readme.md":       0644,
+	} {
+		info, err := os.Stat(filepath.Join(dst, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("expected mode %v of '%s', got %v", mode, path, info.Mode().Perm())
+		}
+		assertModTime(t, info, preserveTimes)
+	}
+
+	info, err := os.Stat(filepath.Join(dst, "empty"))
+	if err != nil {
+		t.Fatalf("expected the empty directory to be kept: %v", err)
+	}
+	if !info.IsDir() {
+		t.Errorf("expected 'empty' to be a directory")
+	}
+
+	for path, target := range map[string]string{
+		"readme.md":      "docs/readme.md",
+		"git_hooks/docs": "../docs",
+	} {
+		link, err := os.Readlink(filepath.Join(dst, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatalf("expected '%s' to be a symlink: %v", path, err)
+		}
+		if link != target {
+			t.Errorf("expected '%s' to point to '%s', got '%s'", path, target, link)
+		}
+	}
+}
+
+// assertModTime checks whether the modification time of the source was kept
+func assertModTime(t *testing.T, info os.FileInfo, preserved bool) {
+	t.Helper()
+	if info.ModTime().Equal(oldTime) != preserved {
+		t.Errorf("expected preserved mtime %v of '%s', got %v", preserved, info.Name(), info.ModTime())
+	}
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "butler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func mkdir(t *testing.T, dir string) {
+	t.Helper()
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeFile writes the file with the mode regardless of the umask
+func writeFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	mkdir(t, filepath.Dir(path))
+	err := ioutil.WriteFile(path, []byte(content), mode)
+	if err == nil {
+		err = os.Chmod(path, mode)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, path string) {
+	t.Helper()
+	mkdir(t, filepath.Dir(path))
+	err := os.Symlink(target, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chtimesTree sets the modification time of all files and directories to oldTime
+func chtimesTree(t *testing.T, root string) {
+	t.Helper()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			return err
+		}
+		return os.Chtimes(path, oldTime, oldTime)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}